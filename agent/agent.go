@@ -0,0 +1,195 @@
+// Package agent holds decrypted secrets in memory (like ssh-agent)
+// so that they can be shared across muss invocations.
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long values are kept when no TTL is requested.
+const DefaultTTL = 8 * time.Hour
+
+// Operations supported by the agent.
+const (
+	opGet  = "get"
+	opSet  = "set"
+	opLock = "lock"
+)
+
+// Each request and response is a single line of json.
+type request struct {
+	Op    string        `json:"op"`
+	Key   string        `json:"key,omitempty"`
+	Value []byte        `json:"value,omitempty"`
+	TTL   time.Duration `json:"ttl,omitempty"`
+}
+
+type response struct {
+	Value []byte `json:"value,omitempty"`
+	Found bool   `json:"found,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Agent holds values in memory until they expire or the agent is locked.
+type Agent struct {
+	// TTL is used for values that are set without one.
+	TTL time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*entry
+}
+
+// New returns an Agent that keeps values for the provided default TTL.
+func New(ttl time.Duration) *Agent {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Agent{
+		TTL:     ttl,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Listen creates the unix socket at the specified path
+// (removing a socket left by an agent that is no longer running).
+func Listen(socket string) (net.Listener, error) {
+	if err := os.MkdirAll(path.Dir(socket), 0700); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(socket); err == nil {
+		if NewClient(socket).Ping() == nil {
+			return nil, fmt.Errorf("an agent is already running at %s", socket)
+		}
+		os.Remove(socket)
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	// Only the current user should be able to talk to the agent.
+	if err := os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve handles connections until the listener is closed.
+func (a *Agent) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go a.handle(conn)
+	}
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		var req request
+		var resp response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %s", err)
+		} else {
+			resp = a.process(req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) process(req request) response {
+	switch req.Op {
+	case opGet:
+		value, found := a.Get(req.Key)
+		return response{Value: value, Found: found}
+	case opSet:
+		a.Set(req.Key, req.Value, req.TTL)
+		return response{}
+	case opLock:
+		a.Lock()
+		return response{}
+	}
+	return response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+}
+
+// Get returns a copy of the value for the key if it has not expired
+// (the stored value is overwritten when it is removed).
+func (a *Agent) Get(key string) ([]byte, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	e, ok := a.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		a.remove(key)
+		return nil, false
+	}
+	return append([]byte(nil), e.value...), true
+}
+
+// Set stores the value for the key until the TTL passes.
+func (a *Agent) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = a.TTL
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.removeExpired()
+	a.entries[key] = &entry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// Lock removes all values from memory.
+func (a *Agent) Lock() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for key := range a.entries {
+		a.remove(key)
+	}
+}
+
+func (a *Agent) removeExpired() {
+	now := time.Now()
+	for key, e := range a.entries {
+		if now.After(e.expires) {
+			a.remove(key)
+		}
+	}
+}
+
+// remove overwrites the value before dropping it
+// so that it doesn't linger in memory.
+func (a *Agent) remove(key string) {
+	if e, ok := a.entries[key]; ok {
+		for i := range e.value {
+			e.value[i] = 0
+		}
+		delete(a.entries, key)
+	}
+}
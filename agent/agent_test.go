@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgent(t *testing.T) {
+	t.Run("get and set", func(t *testing.T) {
+		a := New(0)
+		assert.Equal(t, DefaultTTL, a.TTL)
+
+		_, found := a.Get("k")
+		assert.False(t, found, "not set")
+
+		a.Set("k", []byte("v"), 0)
+		value, found := a.Get("k")
+		assert.True(t, found)
+		assert.Equal(t, "v", string(value))
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		a := New(time.Hour)
+
+		a.Set("short", []byte("v"), time.Millisecond)
+		a.Set("long", []byte("v"), 0)
+		time.Sleep(5 * time.Millisecond)
+
+		_, found := a.Get("short")
+		assert.False(t, found, "expired")
+		_, found = a.Get("long")
+		assert.True(t, found, "default ttl")
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		a := New(time.Hour)
+
+		value := []byte("secret")
+		a.Set("k", value, 0)
+		got, _ := a.Get("k")
+		a.Lock()
+
+		_, found := a.Get("k")
+		assert.False(t, found, "removed")
+		assert.Equal(t, make([]byte, 6), value, "overwritten")
+		assert.Equal(t, "secret", string(got), "values returned are copies")
+	})
+
+	t.Run("unknown operation", func(t *testing.T) {
+		resp := New(0).process(request{Op: "nope"})
+		assert.Equal(t, `unknown operation "nope"`, resp.Error)
+	})
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"time"
+)
+
+// SocketEnvVar can be set to use an agent socket other than the default.
+const SocketEnvVar = "MUSS_AGENT_SOCK"
+
+// How long to wait for the agent so that a hung agent doesn't hang muss.
+const clientTimeout = 2 * time.Second
+
+// SocketPath returns the path of the agent socket.
+func SocketPath() string {
+	if socket := os.Getenv(SocketEnvVar); socket != "" {
+		return socket
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = os.TempDir()
+	}
+	return path.Join(cache, ".muss", "agent.sock")
+}
+
+// Client sends requests to an agent.
+type Client struct {
+	Socket string
+}
+
+// NewClient returns a Client for the agent listening on the socket.
+func NewClient(socket string) *Client {
+	return &Client{Socket: socket}
+}
+
+// Get returns the value for the key (and whether it was found).
+func (c *Client) Get(key string) ([]byte, bool, error) {
+	resp, err := c.send(request{Op: opGet, Key: key})
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// Set stores the value in the agent.
+// A TTL of zero will use the agent's default.
+func (c *Client) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := c.send(request{Op: opSet, Key: key, Value: value, TTL: ttl})
+	return err
+}
+
+// Lock removes all values from the agent.
+func (c *Client) Lock() error {
+	_, err := c.send(request{Op: opLock})
+	return err
+}
+
+// Ping returns an error if the agent is not responding.
+func (c *Client) Ping() error {
+	_, _, err := c.Get("")
+	return err
+}
+
+func (c *Client) send(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.Socket, clientTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("no agent running at %s", c.Socket)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(clientTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to agent: %s", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from agent: %s", err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response from agent: %s", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("agent error: %s", resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestClient(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		socket := path.Join(dir, "agent", "agent.sock")
+		client := NewClient(socket)
+
+		t.Run("no agent", func(t *testing.T) {
+			_, _, err := client.Get("k")
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "no agent running at "+socket, err.Error())
+			}
+		})
+
+		listener, err := Listen(socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		go New(time.Hour).Serve(listener)
+
+		t.Run("socket permissions", func(t *testing.T) {
+			info, err := os.Stat(socket)
+			if assert.Nil(t, err) {
+				assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			}
+		})
+
+		t.Run("requests", func(t *testing.T) {
+			assert.Nil(t, client.Ping())
+
+			_, found, err := client.Get("k")
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			assert.Nil(t, client.Set("k", []byte("v\x00\n"), 0))
+
+			value, found, err := client.Get("k")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "v\x00\n", string(value), "binary safe")
+
+			assert.Nil(t, client.Lock())
+
+			_, found, err = client.Get("k")
+			assert.Nil(t, err)
+			assert.False(t, found, "locked")
+		})
+
+		t.Run("already running", func(t *testing.T) {
+			_, err := Listen(socket)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "an agent is already running at "+socket, err.Error())
+			}
+		})
+	})
+
+	t.Run("socket path", func(t *testing.T) {
+		os.Setenv(SocketEnvVar, "/tmp/muss-test.sock")
+		defer os.Unsetenv(SocketEnvVar)
+
+		assert.Equal(t, "/tmp/muss-test.sock", SocketPath())
+
+		os.Unsetenv(SocketEnvVar)
+		testutil.WithTempDir(t, func(dir string) {
+			assert.Equal(t, path.Join(dir, "test-cache", ".muss", "agent.sock"), SocketPath())
+		})
+	})
+}
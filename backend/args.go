@@ -0,0 +1,91 @@
+package backend
+
+import "strings"
+
+// argRule rewrites an argument (and its value, if any) for the backends
+// that spell it differently from docker-compose.
+type argRule struct {
+	backends []string
+	// The rule only applies to versions since this one (if set).
+	since Version
+	from  []string
+	// to replaces from (nil drops it).
+	to []string
+}
+
+// rules holds the differences between the backends' global arguments
+// so that commands can be written once (using docker-compose v1 arguments).
+// Flags of the compose commands are in capabilities.
+var rules = []argRule{
+	// Compose v2 (which podman also uses) replaced --no-ansi.
+	{backends: []string{"docker", "podman"}, from: []string{"--no-ansi"}, to: []string{"--ansi", "never"}},
+	{backends: []string{"docker-compose"}, since: Version{2, 0, 0}, from: []string{"--no-ansi"}, to: []string{"--ansi", "never"}},
+}
+
+// globalValueFlags are the global compose flags that take a value
+// (as the next arg) so that it isn't mistaken for the command.
+var globalValueFlags = []string{
+	"-f", "--file", "-p", "--project-name", "--project-directory",
+	"--env-file", "-H", "--host", "-c", "--context", "--log-level",
+	"--profile", "--ansi",
+}
+
+// translate applies the rules for the backend to the global flags
+// (the args before the command).  The command and its args are left alone.
+// The version is only probed if a rule that depends on it matches.
+func translate(b Backend, args []string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") || args[i] == "--" {
+			return append(result, args[i:]...)
+		}
+		if contains(globalValueFlags, args[i]) && i+1 < len(args) {
+			result = append(result, args[i], args[i+1])
+			i++
+			continue
+		}
+		if rule := matchRule(b, args[i:]); rule != nil {
+			result = append(result, rule.to...)
+			i += len(rule.from) - 1
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return result
+}
+
+func matchRule(b Backend, args []string) *argRule {
+	for i := range rules {
+		rule := &rules[i]
+		if !contains(rule.backends, b.Name()) || len(args) < len(rule.from) {
+			continue
+		}
+		matched := true
+		for j, arg := range rule.from {
+			if args[j] != arg {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if !rule.since.IsZero() {
+			// An unknown version is assumed to be older.
+			if v, err := b.Version(); err != nil || v.Less(rule.since) {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
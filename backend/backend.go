@@ -0,0 +1,112 @@
+// Package backend builds the command lines used to run compose commands
+// with the container tools that are installed (docker-compose, the
+// "docker compose" plugin, podman-compose or podman).
+package backend
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// EnvVar overrides the backend from the project config.
+const EnvVar = "MUSS_BACKEND"
+
+// Backend builds command lines for a container orchestrator.
+type Backend interface {
+	// Name identifies the backend (as used for the "backend" config key).
+	Name() string
+	// Compose returns the command line for a compose command.
+	Compose(args ...string) []string
+	// Container returns the command line for a container command
+	// (like "attach").
+	Container(args ...string) []string
+	// Version returns the version of the compose command.
+	Version() (Version, error)
+}
+
+// cliBackend is a Backend made from a compose command and a container command.
+type cliBackend struct {
+	name      string
+	compose   []string
+	container string
+}
+
+func (b *cliBackend) Name() string {
+	return b.name
+}
+
+func (b *cliBackend) Compose(args ...string) []string {
+	return append(append([]string{}, b.compose...), translate(b, args)...)
+}
+
+func (b *cliBackend) Container(args ...string) []string {
+	return append([]string{b.container}, args...)
+}
+
+func (b *cliBackend) Version() (Version, error) {
+	return probeVersion(b)
+}
+
+// backends in the order they are preferred when autodetecting.
+var backends = []*cliBackend{
+	{name: "docker-compose", compose: []string{"docker-compose"}, container: "docker"},
+	{name: "docker", compose: []string{"docker", "compose"}, container: "docker"},
+	{name: "podman-compose", compose: []string{"podman-compose"}, container: "podman"},
+	{name: "podman", compose: []string{"podman", "compose"}, container: "podman"},
+}
+
+// Default is used when no backend is configured or detected.
+var Default Backend = backends[0]
+
+// Names returns the names of the available backends (sorted).
+func Names() []string {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = b.name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the backend with the given name.
+func Lookup(name string) (Backend, error) {
+	for _, b := range backends {
+		if b.name == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown backend '%s' (expected one of %s)", name, strings.Join(Names(), ", "))
+}
+
+// Select returns the named backend or, if name is empty, detects one.
+func Select(name string) (Backend, error) {
+	if name == "" {
+		return Detect(), nil
+	}
+	return Lookup(name)
+}
+
+// lookPath and probe can be replaced in tests.
+var lookPath = exec.LookPath
+var probe = func(argv ...string) bool {
+	return exec.Command(argv[0], argv[1:]...).Run() == nil
+}
+
+// Detect returns the first backend whose commands are installed
+// (or Default if none are).
+// Backends that are plugins ("docker compose") are only chosen if the
+// plugin responds.
+func Detect() Backend {
+	for _, b := range backends {
+		if _, err := lookPath(b.compose[0]); err != nil {
+			continue
+		}
+		if len(b.compose) > 1 && !probe(b.Compose("version")...) {
+			continue
+		}
+		return b
+	}
+	return Default
+}
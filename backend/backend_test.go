@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackends(t *testing.T) {
+	t.Run("command lines", func(t *testing.T) {
+		for name, expected := range map[string][][]string{
+			"docker-compose": {{"docker-compose", "ps", "-q"}, {"docker", "attach", "cid"}},
+			"docker":         {{"docker", "compose", "ps", "-q"}, {"docker", "attach", "cid"}},
+			"podman-compose": {{"podman-compose", "ps", "-q"}, {"podman", "attach", "cid"}},
+			"podman":         {{"podman", "compose", "ps", "-q"}, {"podman", "attach", "cid"}},
+		} {
+			b, err := Lookup(name)
+			assert.Nil(t, err)
+			assert.Equal(t, name, b.Name())
+			assert.Equal(t, expected[0], b.Compose("ps", "-q"), name)
+			assert.Equal(t, expected[1], b.Container("attach", "cid"), name)
+		}
+	})
+
+	t.Run("translated args", func(t *testing.T) {
+		docker, _ := Lookup("docker")
+		assert.Equal(t,
+			[]string{"docker", "compose", "--ansi", "never", "up", "--", "--no-ansi"},
+			docker.Compose("--no-ansi", "up", "--", "--no-ansi"))
+		assert.Equal(t,
+			[]string{"docker", "compose", "-f", "a.yml", "--ansi", "never", "run", "app", "--no-ansi"},
+			docker.Compose("-f", "a.yml", "--no-ansi", "run", "app", "--no-ansi"),
+			"only global flags")
+
+		podmanCompose, _ := Lookup("podman-compose")
+		assert.Equal(t,
+			[]string{"podman-compose", "ps", "--services", "--filter", "status=running"},
+			podmanCompose.Compose("ps", "--services", "--filter", "status=running"))
+
+		assert.Equal(t,
+			[]string{"--no-ansi", "ps"},
+			translate(newTestBackend("docker-compose", Version{1, 29, 2}), []string{"--no-ansi", "ps"}))
+		assert.Equal(t,
+			[]string{"--no-ansi", "ps"},
+			translate(newTestBackend("docker-compose", Version{}), []string{"--no-ansi", "ps"}),
+			"unknown version")
+		assert.Equal(t,
+			[]string{"--ansi", "never", "ps"},
+			translate(newTestBackend("docker-compose", Version{2, 20, 2}), []string{"--no-ansi", "ps"}))
+
+		b := newTestBackend("docker-compose", Version{2, 20, 2})
+		translate(b, []string{"-p", "proj", "up", "--no-ansi"})
+		assert.Equal(t, 0, b.probes, "only probed when a rule matches")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := Select("rocket")
+		assert.Equal(t, "unknown backend 'rocket' (expected one of docker, docker-compose, podman, podman-compose)", err.Error())
+	})
+
+	t.Run("detect", func(t *testing.T) {
+		origLookPath, origProbe := lookPath, probe
+		defer func() { lookPath, probe = origLookPath, origProbe }()
+
+		var installed map[string]bool
+		var probed [][]string
+		lookPath = func(file string) (string, error) {
+			if installed[file] {
+				return "/bin/" + file, nil
+			}
+			return "", errors.New("not found")
+		}
+		probe = func(argv ...string) bool {
+			probed = append(probed, argv)
+			return argv[0] == "docker"
+		}
+
+		detect := func(names ...string) string {
+			installed = make(map[string]bool)
+			for _, name := range names {
+				installed[name] = true
+			}
+			b, err := Select("")
+			assert.Nil(t, err)
+			return b.Name()
+		}
+
+		assert.Equal(t, "docker-compose", detect("docker-compose", "docker", "podman"))
+		assert.Nil(t, probed, "plugin not probed when not needed")
+
+		assert.Equal(t, "docker", detect("docker", "podman"))
+		assert.Equal(t, [][]string{{"docker", "compose", "version"}}, probed)
+
+		assert.Equal(t, "podman-compose", detect("podman-compose", "podman"))
+
+		// The plugin doesn't respond.
+		probe = func(argv ...string) bool { return false }
+		assert.Equal(t, "podman-compose", detect("docker", "podman-compose"))
+
+		assert.Equal(t, "docker-compose", detect(), "default")
+	})
+}
@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What to do with a flag that a backend (or version) does not support.
+const (
+	// Replace the flag with others.
+	translateFlag = iota
+	// Drop the flag (with a warning).
+	dropFlag
+	// Refuse to run the command.
+	rejectFlag
+)
+
+// capability describes a flag of a compose command that is not supported
+// by every backend (or every version of one).
+type capability struct {
+	backends []string
+	command  string
+	flag     string
+	// The entry applies to versions from since up to (but not including)
+	// until; zero values are unbounded.
+	// Entries with bounds are skipped when the version is unknown.
+	since, until Version
+	action       int
+	// to replaces the flag when translating.
+	to []string
+}
+
+// capabilities lists the differences in the flags muss passes to compose.
+var capabilities = []capability{
+	{backends: []string{"docker-compose"}, command: "pull", flag: "--include-deps", until: Version{1, 21, 0}, action: rejectFlag},
+	{backends: []string{"docker-compose"}, command: "up", flag: "--renew-anon-volumes", until: Version{1, 22, 0}, action: rejectFlag},
+	{backends: []string{"docker-compose"}, command: "up", flag: "--quiet-pull", until: Version{1, 23, 0}, action: dropFlag},
+	// Compose v2 (the plugin or the standalone docker-compose 2.x)
+	// always pulls in parallel.
+	{backends: []string{"docker", "docker-compose", "podman"}, command: "pull", flag: "--no-parallel", since: Version{2, 0, 0}, action: dropFlag},
+	// Compose v2 uses the global --ansi flag (which it accepts after the command).
+	{backends: []string{"docker", "docker-compose", "podman"}, command: "logs", flag: "--no-color", since: Version{2, 0, 0}, action: translateFlag, to: []string{"--ansi=never"}},
+	{backends: []string{"podman-compose"}, command: "pull", flag: "--include-deps", action: dropFlag},
+	{backends: []string{"podman-compose"}, command: "pull", flag: "--no-parallel", action: dropFlag},
+	// podman-compose can only list services (not filter them).
+	{backends: []string{"podman-compose"}, command: "ps", flag: "--filter", action: rejectFlag},
+}
+
+func (c *capability) versioned() bool {
+	return !c.since.IsZero() || !c.until.IsZero()
+}
+
+// covers returns true if the (known) version is within the bounds.
+func (c *capability) covers(v Version) bool {
+	if v.IsZero() {
+		return false
+	}
+	return !v.Less(c.since) && (c.until.IsZero() || v.Less(c.until))
+}
+
+func (c *capability) describe(name string, v Version) string {
+	if !c.versioned() {
+		return name
+	}
+	return fmt.Sprintf("%s %s", name, v)
+}
+
+// CheckFlags adapts the flags for a compose command to the backend:
+// flags it does not support are translated, dropped (returning a warning),
+// or rejected (returning an error).
+// The version is only probed if a capability depends on it
+// and an unknown version is assumed to support everything.
+func CheckFlags(b Backend, command string, flags []string) ([]string, []string, error) {
+	var v Version
+	probed := false
+
+	result := make([]string, 0, len(flags))
+	var warnings []string
+	for _, flag := range flags {
+		name := strings.SplitN(flag, "=", 2)[0]
+		var match *capability
+		for i := range capabilities {
+			c := &capabilities[i]
+			if !contains(c.backends, b.Name()) || c.command != command || c.flag != name {
+				continue
+			}
+			if c.versioned() {
+				if !probed {
+					v, _ = b.Version()
+					probed = true
+				}
+				if !c.covers(v) {
+					continue
+				}
+			}
+			match = c
+			break
+		}
+		if match == nil {
+			result = append(result, flag)
+			continue
+		}
+
+		switch match.action {
+		case translateFlag:
+			result = append(result, match.to...)
+		case dropFlag:
+			warnings = append(warnings, fmt.Sprintf("%s does not support %s %s (ignoring it)", match.describe(b.Name(), v), command, name))
+		case rejectFlag:
+			msg := fmt.Sprintf("%s does not support %s %s", match.describe(b.Name(), v), command, name)
+			if !match.until.IsZero() {
+				msg += fmt.Sprintf(" (requires %s)", match.until)
+			}
+			return nil, warnings, fmt.Errorf("%s", msg)
+		}
+	}
+	return result, warnings, nil
+}
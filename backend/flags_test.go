@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBackend struct {
+	*cliBackend
+	version Version
+	probes  int
+}
+
+func (b *testBackend) Version() (Version, error) {
+	b.probes++
+	if b.version.IsZero() {
+		return Version{}, errors.New("unknown")
+	}
+	return b.version, nil
+}
+
+func newTestBackend(name string, v Version) *testBackend {
+	b, _ := Lookup(name)
+	return &testBackend{cliBackend: b.(*cliBackend), version: v}
+}
+
+func TestCheckFlags(t *testing.T) {
+	t.Run("supported", func(t *testing.T) {
+		b := newTestBackend("docker-compose", Version{1, 29, 2})
+		flags, warnings, err := CheckFlags(b, "pull", []string{"--include-deps", "--quiet"})
+		assert.Nil(t, err)
+		assert.Nil(t, warnings)
+		assert.Equal(t, []string{"--include-deps", "--quiet"}, flags)
+	})
+
+	t.Run("only probe when needed", func(t *testing.T) {
+		b := newTestBackend("docker-compose", Version{1, 29, 2})
+		CheckFlags(b, "ps", []string{"--quiet", "--all"})
+		assert.Equal(t, 0, b.probes)
+
+		CheckFlags(b, "up", []string{"--quiet-pull", "--renew-anon-volumes"})
+		assert.Equal(t, 1, b.probes)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		b := newTestBackend("docker-compose", Version{1, 20, 1})
+		_, _, err := CheckFlags(b, "pull", []string{"--quiet", "--include-deps"})
+		assert.Equal(t, "docker-compose 1.20.1 does not support pull --include-deps (requires 1.21.0)", err.Error())
+
+		b = newTestBackend("podman-compose", Version{})
+		_, _, err = CheckFlags(b, "ps", []string{"--services", "--filter=status=running"})
+		assert.Equal(t, "podman-compose does not support ps --filter", err.Error())
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		b := newTestBackend("docker-compose", Version{1, 22, 0})
+		flags, warnings, err := CheckFlags(b, "up", []string{"--quiet-pull", "--detach", "--renew-anon-volumes"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"docker-compose 1.22.0 does not support up --quiet-pull (ignoring it)"}, warnings)
+		assert.Equal(t, []string{"--detach", "--renew-anon-volumes"}, flags)
+
+		b = newTestBackend("podman-compose", Version{})
+		flags, warnings, _ = CheckFlags(b, "pull", []string{"--include-deps"})
+		assert.Equal(t, []string{"podman-compose does not support pull --include-deps (ignoring it)"}, warnings)
+		assert.Equal(t, []string{}, flags)
+		assert.Equal(t, 0, b.probes)
+	})
+
+	t.Run("translate", func(t *testing.T) {
+		b := newTestBackend("docker", Version{2, 20, 2})
+		flags, warnings, err := CheckFlags(b, "logs", []string{"--no-color", "--tail=all"})
+		assert.Nil(t, err)
+		assert.Nil(t, warnings)
+		assert.Equal(t, []string{"--ansi=never", "--tail=all"}, flags)
+
+		b = newTestBackend("docker-compose", Version{2, 20, 2})
+		flags, warnings, err = CheckFlags(b, "logs", []string{"--no-color"})
+		assert.Nil(t, err)
+		assert.Nil(t, warnings)
+		assert.Equal(t, []string{"--ansi=never"}, flags, "standalone compose v2")
+
+		b = newTestBackend("docker-compose", Version{1, 29, 2})
+		flags, _, _ = CheckFlags(b, "logs", []string{"--no-color"})
+		assert.Equal(t, []string{"--no-color"}, flags, "compose v1")
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		b := newTestBackend("docker-compose", Version{})
+		flags, warnings, err := CheckFlags(b, "up", []string{"--quiet-pull"})
+		assert.Nil(t, err)
+		assert.Nil(t, warnings)
+		assert.Equal(t, []string{"--quiet-pull"}, flags, "assumed to be supported")
+	})
+}
@@ -0,0 +1,26 @@
+package backend
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	legacyProjectChars = regexp.MustCompile(`[^a-z0-9]`)
+	projectChars       = regexp.MustCompile(`[^a-z0-9_-]`)
+)
+
+// DefaultProjectName returns the project name that the backend derives
+// from the directory (when COMPOSE_PROJECT_NAME isn't set).
+// docker-compose before v2 removed everything but letters and numbers;
+// later versions (and other backends) also keep dashes and underscores.
+func DefaultProjectName(b Backend, dir string) string {
+	name := strings.ToLower(filepath.Base(dir))
+	if b.Name() == "docker-compose" {
+		if v, err := b.Version(); err != nil || v.Major < 2 {
+			return legacyProjectChars.ReplaceAllString(name, "")
+		}
+	}
+	return strings.TrimLeft(projectChars.ReplaceAllString(name, ""), "_-")
+}
@@ -0,0 +1,16 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultProjectName(t *testing.T) {
+	dir := "/src/My_Project-2"
+	assert.Equal(t, "myproject2", DefaultProjectName(newTestBackend("docker-compose", Version{1, 29, 2}), dir))
+	assert.Equal(t, "myproject2", DefaultProjectName(newTestBackend("docker-compose", Version{}), dir), "unknown version")
+	assert.Equal(t, "my_project-2", DefaultProjectName(newTestBackend("docker-compose", Version{2, 20, 2}), dir))
+	assert.Equal(t, "my_project-2", DefaultProjectName(newTestBackend("docker", Version{}), dir))
+	assert.Equal(t, "app", DefaultProjectName(newTestBackend("podman", Version{}), "/src/_App"))
+}
@@ -0,0 +1,158 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is the version of a backend's compose command.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion reads a version like "1.29.2", "v2.20.2"
+// or "1.29.2, build 5becea4c".
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version '%s'", strings.TrimSpace(s))
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+// IsZero returns true for the zero Version (which means unknown or unbounded).
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Less returns true if v is older than other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// VersionCacheFile holds the versions that have been probed keyed by
+// the path and modification time of the binary (so that an upgrade will be
+// noticed).  Set it to "" to only remember versions in memory.
+var VersionCacheFile = defaultVersionCacheFile()
+
+func defaultVersionCacheFile() string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(cache, ".muss", "backend-versions.json")
+}
+
+var versionMutex sync.Mutex
+var probedVersions = make(map[string]Version)
+
+// output can be replaced in tests.
+var output = func(argv ...string) (string, error) {
+	out, err := exec.Command(argv[0], argv[1:]...).Output()
+	return string(out), err
+}
+
+// probeVersion runs the backend's version command once per binary
+// (as identified by its path and modification time).
+// The compose plugins of the docker and podman CLIs can be upgraded without
+// changing the CLI binary so their versions are only remembered in memory.
+func probeVersion(b *cliBackend) (Version, error) {
+	plugin := len(b.compose) > 1
+	bin, err := lookPath(b.compose[0])
+	if err != nil {
+		return Version{}, err
+	}
+	info, err := os.Stat(bin)
+	if err != nil {
+		return Version{}, err
+	}
+	key := fmt.Sprintf("%s %s %d", b.name, bin, info.ModTime().UnixNano())
+
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+
+	if v, ok := probedVersions[key]; ok {
+		return v, nil
+	}
+	cached := make(map[string]string)
+	if !plugin {
+		cached = readVersionCache()
+	}
+	if s, ok := cached[key]; ok {
+		if v, err := ParseVersion(s); err == nil {
+			probedVersions[key] = v
+			return v, nil
+		}
+	}
+
+	out, err := output(b.Compose("version", "--short")...)
+	if err != nil {
+		return Version{}, fmt.Errorf("failed to get %s version: %w", b.name, err)
+	}
+	v, err := ParseVersion(out)
+	if err != nil {
+		return Version{}, err
+	}
+
+	probedVersions[key] = v
+	if !plugin {
+		cached[key] = v.String()
+		writeVersionCache(cached)
+	}
+	return v, nil
+}
+
+func readVersionCache() map[string]string {
+	cached := make(map[string]string)
+	if VersionCacheFile == "" {
+		return cached
+	}
+	if content, err := ioutil.ReadFile(VersionCacheFile); err == nil {
+		// Start over if the file is corrupt.
+		if json.Unmarshal(content, &cached) != nil {
+			cached = make(map[string]string)
+		}
+	}
+	return cached
+}
+
+// writeVersionCache saves the versions (ignoring errors as the cache is
+// only an optimization).
+func writeVersionCache(cached map[string]string) {
+	if VersionCacheFile == "" {
+		return
+	}
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(path.Dir(VersionCacheFile), 0700) == nil {
+		ioutil.WriteFile(VersionCacheFile, content, 0600)
+	}
+}
@@ -0,0 +1,133 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestVersion(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		for input, expected := range map[string]Version{
+			"1.29.2\n":                {1, 29, 2},
+			"v2.20.2":                 {2, 20, 2},
+			"2.21.0-desktop.1":        {2, 21, 0},
+			"1.25.0, build 0a186604":  {1, 25, 0},
+			"1.0":                     {1, 0, 0},
+			"podman-compose 1.0.6...": {},
+		} {
+			v, err := ParseVersion(input)
+			if expected.IsZero() {
+				assert.NotNil(t, err, input)
+				continue
+			}
+			assert.Nil(t, err, input)
+			assert.Equal(t, expected, v, input)
+		}
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		assert.True(t, Version{1, 29, 2}.Less(Version{2, 0, 0}))
+		assert.True(t, Version{2, 1, 9}.Less(Version{2, 2, 0}))
+		assert.True(t, Version{2, 2, 0}.Less(Version{2, 2, 1}))
+		assert.False(t, Version{2, 2, 1}.Less(Version{2, 2, 1}))
+		assert.Equal(t, "2.2.1", Version{2, 2, 1}.String())
+	})
+
+	t.Run("probe", func(t *testing.T) {
+		dir := testutil.Tempdir(t)
+		defer os.RemoveAll(dir)
+
+		bin := path.Join(dir, "docker-compose")
+		testutil.WriteFile(t, bin, "")
+
+		origLookPath, origOutput, origCacheFile := lookPath, output, VersionCacheFile
+		defer func() { lookPath, output, VersionCacheFile = origLookPath, origOutput, origCacheFile }()
+
+		lookPath = func(string) (string, error) { return bin, nil }
+		VersionCacheFile = path.Join(dir, "cache", "versions.json")
+
+		var probes [][]string
+		version := "1.29.2, build 5becea4c\n"
+		output = func(argv ...string) (string, error) {
+			probes = append(probes, argv)
+			return version, nil
+		}
+
+		b, _ := Lookup("docker-compose")
+
+		v, err := b.Version()
+		assert.Nil(t, err)
+		assert.Equal(t, Version{1, 29, 2}, v)
+		assert.Equal(t, [][]string{{"docker-compose", "version", "--short"}}, probes)
+
+		v, _ = b.Version()
+		assert.Equal(t, Version{1, 29, 2}, v)
+		assert.Equal(t, 1, len(probes), "remembered")
+
+		// Start a new process.
+		probedVersions = make(map[string]Version)
+		v, _ = b.Version()
+		assert.Equal(t, Version{1, 29, 2}, v)
+		assert.Equal(t, 1, len(probes), "cached in file")
+
+		// Upgrade.
+		version = "1.29.3"
+		later := time.Now().Add(time.Minute)
+		os.Chtimes(bin, later, later)
+		v, _ = b.Version()
+		assert.Equal(t, Version{1, 29, 3}, v)
+		assert.Equal(t, 2, len(probes), "probed again when binary changes")
+
+		output = func(argv ...string) (string, error) {
+			return "", errors.New("exit status 1")
+		}
+		os.Chtimes(bin, later.Add(time.Minute), later.Add(time.Minute))
+		_, err = b.Version()
+		assert.Equal(t, "failed to get docker-compose version: exit status 1", err.Error())
+	})
+
+	t.Run("plugin", func(t *testing.T) {
+		dir := testutil.Tempdir(t)
+		defer os.RemoveAll(dir)
+
+		bin := path.Join(dir, "docker")
+		testutil.WriteFile(t, bin, "")
+
+		origLookPath, origOutput, origCacheFile := lookPath, output, VersionCacheFile
+		defer func() { lookPath, output, VersionCacheFile = origLookPath, origOutput, origCacheFile }()
+
+		lookPath = func(string) (string, error) { return bin, nil }
+		VersionCacheFile = path.Join(dir, "cache", "versions.json")
+
+		probes := 0
+		version := "v2.20.2\n"
+		output = func(argv ...string) (string, error) {
+			probes++
+			return version, nil
+		}
+
+		b, _ := Lookup("docker")
+
+		v, _ := b.Version()
+		assert.Equal(t, Version{2, 20, 2}, v)
+		v, _ = b.Version()
+		assert.Equal(t, 1, probes, "remembered")
+
+		// Upgrade just the compose plugin and start a new process.
+		version = "v2.21.0\n"
+		probedVersions = make(map[string]Version)
+		v, _ = b.Version()
+		assert.Equal(t, Version{2, 21, 0}, v)
+		assert.Equal(t, 2, probes, "not cached in file")
+
+		_, err := os.Stat(VersionCacheFile)
+		assert.True(t, os.IsNotExist(err), "cache file not written")
+	})
+}
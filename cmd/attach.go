@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-	"strings"
-
 	"github.com/spf13/cobra"
 
 	"gerrit.instructure.com/muss/config"
@@ -20,25 +17,18 @@ func newAttachCommand(cfg *config.ProjectConfig) *cobra.Command {
 		PreRunE: configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			service := args[0]
-			cid, err := dockerContainerID(service)
+			cid, err := containerID(cfg, service, index)
 			if err != nil {
 				return err
 			}
 
-			lines := strings.Split(cid, "\n")
-			if len(lines) >= index {
-				cid = lines[index-1]
-			} else {
-				return fmt.Errorf("Index %d not found for service %s", index, service)
-			}
-
 			cmdArgs := []string{"attach"}
 			cmdArgs = append(cmdArgs, (flagDumper{visitAll: true, showFalseBools: true}).fromCmd(cmd)...)
 			cmdArgs = append(cmdArgs, cid)
 
 			return DelegateCmd(
 				cmd,
-				dockerCmd(cmdArgs...),
+				containerCmd(cmdArgs...),
 			)
 		},
 	}
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
 )
 
 func TestAttachCommand(t *testing.T) {
@@ -86,5 +88,31 @@ second:foo:cid
 			assert.Equal(t, "", stderr)
 			assert.Equal(t, expOut, stdout)
 		})
+
+		t.Run("engine", func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"project_name": "proj"})
+			withFakeEngine(t, []testutil.FakeContainer{
+				{ID: "foo1", Project: "proj", Service: "foo", Number: 1},
+				{ID: "foo3", Project: "proj", Service: "foo", Number: 3},
+				{ID: "other3", Project: "other", Service: "foo", Number: 3},
+			}, func(t *testing.T) {
+				stdout, stderr, err := runTestCommand(cfg, []string{"attach", "foo", "--index", "3"})
+
+				expOut := `docker
+attach
+--detach-keys=ctrl-c
+--no-stdin=false
+--sig-proxy=false
+foo3
+`
+
+				assert.Nil(t, err)
+				assert.Equal(t, "", stderr)
+				assert.Equal(t, expOut, stdout)
+
+				_, _, err = runTestCommand(cfg, []string{"attach", "foo", "--index", "2"})
+				assert.Equal(t, "Index 2 not found for service foo", err.Error())
+			})
+		})
 	})
 }
@@ -31,14 +31,20 @@ Options:
 		DisableFlagParsing: true,
 		PreRunE:            configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			args, err := expandServiceArgs(cfg, args, "-m", "--memory", "--build-arg")
+			if err != nil {
+				return err
+			}
 			delegator := cmdDelegator(cmd)
-			err := delegator.FilterStderr(newDCErrorFilter(cfg))
+			err = delegator.FilterStderr(newDCErrorFilter(cfg))
+			if err != nil {
+				return err
+			}
+			dcCmd, err := dockerComposeCmd(cmd, args)
 			if err != nil {
 				return err
 			}
-			return delegator.Delegate(
-				dockerComposeCmd(cmd, args),
-			)
+			return delegator.Delegate(dcCmd)
 		},
 	}
 
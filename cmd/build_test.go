@@ -41,6 +41,13 @@ svc2
 			assert.Equal(t, expOut, stdout)
 		})
 
+		t.Run("selectors", func(t *testing.T) {
+			stdout, _, err := runTestCommand(newSelectorsConfig(t), []string{"build", "--build-arg", "k=v", "!@backend"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "docker-compose\nbuild\n--build-arg\nk=v\nweb\n", stdout)
+		})
+
 		t.Run("build with private registry 403 with service def", func(t *testing.T) {
 			os.Setenv("MUSS_TEST_REGISTRY_ERROR", "403")
 			defer os.Unsetenv("MUSS_TEST_REGISTRY_ERROR")
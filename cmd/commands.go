@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"gerrit.instructure.com/muss/config"
+)
+
+// addProjectCommands adds the commands defined in the project config
+// (skipping any that would replace a muss command).
+func addProjectCommands(cfg *config.ProjectConfig, root *cobra.Command) {
+	if cfg == nil {
+		return
+	}
+	for _, name := range cfg.CommandNames() {
+		if sub, _, err := root.Find([]string{name}); err == nil && sub != root {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("Ignoring project command '%s' (it would replace a muss command).", name))
+			continue
+		}
+		root.AddCommand(newProjectCommand(cfg, name, cfg.Commands[name]))
+	}
+}
+
+func newProjectCommand(cfg *config.ProjectConfig, name string, command *config.Command) *cobra.Command {
+	short := command.Description
+	if short == "" {
+		short = fmt.Sprintf("Run %s (project command)", name)
+	}
+
+	min, max := command.ArgCount()
+	args := cobra.RangeArgs(min, max)
+	if max < 0 {
+		args = cobra.MinimumNArgs(min)
+	}
+
+	cmd := &cobra.Command{
+		Use:   strings.Join(append([]string{name}, command.Args...), " "),
+		Short: short,
+		Args:  args,
+		// Pass any flags to the command.
+		DisableFlagParsing: true,
+		PreRunE:            configSavePreRun(cfg),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNeeds(cmd, cfg, name, command.Needs); err != nil {
+				return err
+			}
+			return DelegateCmd(cmd, projectCmd(cmd, name, command, args))
+		},
+	}
+
+	return cmd
+}
+
+// checkNeeds returns an error if any of the services are not running
+// (and warns if it can't tell).
+func checkNeeds(cmd *cobra.Command, cfg *config.ProjectConfig, name string, needs []string) error {
+	if len(needs) == 0 {
+		return nil
+	}
+	services, err := runningServices(cfg, needs)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: unable to check the services %s needs (%s): %s\n", name, strings.Join(needs, ", "), err)
+		return nil
+	}
+	running := make(map[string]bool)
+	for _, svc := range services {
+		running[svc] = true
+	}
+	missing := make([]string, 0, len(needs))
+	for _, svc := range needs {
+		if !running[svc] {
+			missing = append(missing, svc)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return NewQuietError(fmt.Errorf("%s needs services that are not running: %s (start them with \"muss up --detach %s\")",
+		name, strings.Join(missing, ", "), strings.Join(missing, " ")))
+}
+
+// projectCmd runs the command on the host or in its service's container.
+func projectCmd(cmd *cobra.Command, name string, command *config.Command, args []string) *exec.Cmd {
+	argv := append(command.Argv(name), args...)
+	if command.Service == "" {
+		return exec.Command(argv[0], argv[1:]...)
+	}
+	execArgs := []string{"exec"}
+	// Compose allocates a tty by default which fails without one.
+	if !isTerminal(cmd.InOrStdin()) {
+		execArgs = append(execArgs, "-T")
+	}
+	execArgs = append(execArgs, command.Service)
+	return composeCmd(append(execArgs, argv...)...)
+}
+
+func isTerminal(r interface{}) bool {
+	f, ok := r.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
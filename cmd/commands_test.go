@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestProjectCommands(t *testing.T) {
+	commands := map[string]interface{}{
+		"console": map[string]interface{}{
+			"description": "Open a rails console",
+			"exec":        []interface{}{"bin/rails", "console"},
+			"service":     "app",
+			"needs":       []interface{}{"app", "db"},
+		},
+		"lint": map[string]interface{}{
+			"args":   []interface{}{"FILE..."},
+			"script": `lint "$@"`,
+		},
+		"deploy": map[string]interface{}{
+			"args": []interface{}{"ENV", "[VERSION]"},
+			"exec": []interface{}{"deploy-tool", "--"},
+		},
+		"up": map[string]interface{}{
+			"exec": []interface{}{"echo"},
+		},
+	}
+
+	t.Run("help", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]interface{}{"commands": commands})
+
+		stdout, _, err := runTestCommand(cfg, []string{"help"})
+
+		assert.Nil(t, err)
+		assert.Contains(t, stdout, "console     Open a rails console\n")
+		assert.Contains(t, stdout, "deploy      Run deploy (project command)\n")
+
+		stdout, _, err = runTestCommand(cfg, []string{"help", "deploy"})
+
+		assert.Nil(t, err)
+		assert.Contains(t, stdout, "muss deploy ENV [VERSION]")
+	})
+
+	t.Run("muss commands win", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]interface{}{"commands": commands})
+
+		root := NewRootCommand(cfg)
+		up, _, _ := root.Find([]string{"up"})
+
+		assert.Equal(t, "Create and start containers", up.Short)
+		assert.Equal(t, []string{"Ignoring project command 'up' (it would replace a muss command)."}, cfg.Warnings)
+	})
+
+	t.Run("host exec", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "deploy-tool")
+		defer fake.Close()
+		fake.On("deploy-tool", nil, testutil.FakeResponse{Stdout: "deployed\n"})
+
+		cfg := newTestConfig(t, map[string]interface{}{"commands": commands})
+
+		stdout, _, err := runTestCommand(cfg, []string{"deploy", "staging", "--force"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deployed\n", stdout)
+		assert.Equal(t, [][]string{{"--", "staging", "--force"}}, fake.Args("deploy-tool"))
+	})
+
+	t.Run("script", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "lint")
+		defer fake.Close()
+
+		cfg := newTestConfig(t, map[string]interface{}{"commands": commands})
+
+		_, _, err := runTestCommand(cfg, []string{"lint", "a.go", "b c.go"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{{"a.go", "b c.go"}}, fake.Args("lint"))
+	})
+
+	t.Run("arg count", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]interface{}{"commands": commands})
+
+		_, _, err := runTestCommand(cfg, []string{"lint"})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "requires at least 1 arg(s), only received 0", err.Error())
+		}
+
+		_, _, err = runTestCommand(cfg, []string{"deploy", "a", "b", "c"})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "accepts between 1 and 2 arg(s), received 3", err.Error())
+		}
+	})
+
+	t.Run("service with needs", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		// Without a terminal compose shouldn't allocate a tty.
+		stdin := os.Stdin
+		defer func() { os.Stdin = stdin }()
+		os.Stdin, _ = os.Open(os.DevNull)
+
+		cfg := newTestConfig(t, map[string]interface{}{"project_name": "proj", "commands": commands})
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "app1", Project: "proj", Service: "app", Number: 1},
+			{ID: "db1", Project: "proj", Service: "db", Number: 1},
+		}, func(t *testing.T) {
+			_, _, err := runTestCommand(cfg, []string{"console", "-e", "test"})
+
+			assert.Nil(t, err)
+			assert.Equal(t,
+				[][]string{{"exec", "-T", "app", "bin/rails", "console", "-e", "test"}},
+				fake.Args("docker-compose"))
+		})
+	})
+
+	t.Run("needs not running", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		cfg := newTestConfig(t, map[string]interface{}{"project_name": "proj", "commands": commands})
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "app1", Project: "proj", Service: "app", Number: 1},
+			{ID: "db1", Project: "proj", Service: "db", Number: 1, State: "exited"},
+		}, func(t *testing.T) {
+			_, _, err := runTestCommand(cfg, []string{"console"})
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, `console needs services that are not running: db (start them with "muss up --detach db")`, err.Error())
+			}
+			assert.Len(t, fake.Calls("docker-compose"), 0)
+		})
+	})
+	t.Run("needs unknown", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "podman-compose")
+		defer fake.Close()
+
+		stdin := os.Stdin
+		defer func() { os.Stdin = stdin }()
+		os.Stdin, _ = os.Open(os.DevNull)
+
+		cfg := newTestConfig(t, map[string]interface{}{"backend": "podman-compose", "commands": commands})
+		defer setBackend(nil)
+
+		_, stderr, err := runTestCommand(cfg, []string{"console"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Warning: unable to check the services console needs (app, db): podman-compose does not support ps --filter\n", stderr)
+		assert.Equal(t,
+			[][]string{{"exec", "-T", "app", "bin/rails", "console"}},
+			fake.Args("podman-compose"))
+	})
+}
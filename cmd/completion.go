@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"gerrit.instructure.com/muss/config"
+)
+
+func newCompletionCommand(_ *config.ProjectConfig) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "completion [bash|zsh]",
+		Short: "Output a shell completion script",
+		Long: `Output a shell completion script (for bash by default).
+
+The script includes project commands and plugins found when it is generated.
+
+  source <(muss completion bash)
+`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"bash", "zsh"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && args[0] == "zsh" {
+				return cmd.Root().GenZshCompletion(cmd.OutOrStdout())
+			}
+			if len(args) > 0 && args[0] != "bash" {
+				return NewQuietError(fmt.Errorf("unsupported shell '%s' (expected bash or zsh)", args[0]))
+			}
+			return cmd.Root().GenBashCompletion(cmd.OutOrStdout())
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	AddCommandBuilder(newCompletionCommand)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionCommand(t *testing.T) {
+	stdout, _, err := runTestCommand(nil, []string{"completion", "zsh"})
+
+	assert.Nil(t, err)
+	assert.Contains(t, stdout, "#compdef _muss muss")
+
+	_, _, err = runTestCommand(nil, []string{"completion", "fish"})
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "unsupported shell 'fish' (expected bash or zsh)", err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"text/template"
@@ -10,11 +11,13 @@ import (
 
 	rootcmd "gerrit.instructure.com/muss/cmd"
 	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/proc"
 )
 
 var format = "{{ yaml . }}"
 
 func newShowCommand(cfg *config.ProjectConfig) *cobra.Command {
+	unmask := false
 	var cmd = &cobra.Command{
 		Use:   "show",
 		Short: "Show muss config",
@@ -38,12 +41,21 @@ Template examples:
   '{{ range .service_definitions }}{{ range $k, $v := .configs }}{{ $k }}{{ "\n" }}{{ end }}{{end }}'
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := processTemplate(format, cfg, cmd.OutOrStdout())
-			return rootcmd.QuietErrorOrNil(err)
+			var output bytes.Buffer
+			if err := processTemplate(format, cfg, &output); err != nil {
+				return rootcmd.QuietErrorOrNil(err)
+			}
+			content := output.Bytes()
+			if !unmask {
+				content = proc.Redact(content, config.SecretValues())
+			}
+			_, err := cmd.OutOrStdout().Write(content)
+			return err
 		},
 	}
 
 	cmd.Flags().StringVar(&format, "format", format, "Format the output using the given Go template")
+	cmd.Flags().BoolVar(&unmask, "unmask", false, "Do not mask secret values in the output")
 
 	return cmd
 }
@@ -148,6 +148,54 @@ func TestConfigShow(t *testing.T) {
 			".user (key)")
 	})
 
+	t.Run("masks secrets", func(t *testing.T) {
+		os.Unsetenv("MUSS_TEST_SHOW_MASK")
+		defer os.Unsetenv("MUSS_TEST_SHOW_MASK")
+
+		cfg, err := config.NewConfigFromMap(map[string]interface{}{
+			"project_name": "lo-and-behold",
+			"secret_commands": map[string]interface{}{
+				"show": map[string]interface{}{
+					"exec":  []interface{}{"echo"},
+					"cache": "none",
+				},
+			},
+			"service_definitions": []map[string]interface{}{
+				{
+					"name": "app",
+					"configs": map[string]interface{}{
+						"sole": map[string]interface{}{
+							"secrets": map[string]interface{}{
+								"MUSS_TEST_SHOW_MASK": map[string]interface{}{
+									"show": []interface{}{"behold"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Load the secrets (like commands that save the config do).
+		if _, err := cfg.ComposeConfig(); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.LoadEnv(); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t,
+			"lo-and-********",
+			showOut(t, cfg, "{{ .project_name }}"),
+			"masked")
+
+		stdout, _, ec := testShowCommand(t, cfg, []string{"--unmask", "--format", "{{ .project_name }}"})
+		assert.Equal(t, 0, ec)
+		assert.Equal(t, "lo-and-behold", stdout, "--unmask")
+	})
+
 	t.Run("without service defs", func(t *testing.T) {
 		if dir, err := os.Getwd(); err != nil {
 			t.Fatal(err)
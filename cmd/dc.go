@@ -27,7 +27,7 @@ Usage:
 		PreRunE:            configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			return proc.Exec(append([]string{"docker-compose"}, args...))
+			return proc.Exec(composeBackend().Compose(args...))
 		},
 	}
 
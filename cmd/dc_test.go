@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"gerrit.instructure.com/muss/config"
 	"gerrit.instructure.com/muss/proc"
 )
 
@@ -25,5 +26,21 @@ func TestDcCommand(t *testing.T) {
 				proc.LastExecArgv,
 				"exec")
 		})
+
+		t.Run("backend", func(t *testing.T) {
+			cfg, _ := config.NewConfigFromMap(map[string]interface{}{"backend": "docker"})
+			_, _, err := runTestCommand(cfg, []string{
+				"dc",
+				"--no-ansi",
+				"down",
+			})
+
+			assert.Nil(t, err)
+
+			assert.Equal(t,
+				[]string{"docker", "compose", "--ansi", "never", "down"},
+				proc.LastExecArgv,
+				"exec")
+		})
 	})
 }
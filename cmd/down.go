@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"gerrit.instructure.com/muss/config"
@@ -40,10 +43,14 @@ Options:
 		DisableFlagParsing: true,
 		PreRunE:            configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return DelegateCmd(
-				cmd,
-				dockerComposeCmd(cmd, args),
-			)
+			delegator := cmdDelegator(cmd)
+			// Flags aren't parsed so look for the timeout in the args.
+			delegator.GracePeriod = gracePeriodFromTimeout(downTimeout(args))
+			dcCmd, err := dockerComposeCmd(cmd, args)
+			if err != nil {
+				return err
+			}
+			return delegator.Delegate(dcCmd)
 		},
 	}
 
@@ -53,6 +60,33 @@ Options:
 	return cmd
 }
 
+// downTimeout returns the value of -t/--timeout
+// (or the docker-compose default).
+func downTimeout(args []string) int {
+	timeout := 10
+	for i, arg := range args {
+		var value string
+		switch {
+		case arg == "--":
+			return timeout
+		case arg == "-t" || arg == "--timeout":
+			if i+1 < len(args) {
+				value = args[i+1]
+			}
+		case strings.HasPrefix(arg, "--timeout="):
+			value = strings.TrimPrefix(arg, "--timeout=")
+		case strings.HasPrefix(arg, "-t"):
+			value = strings.TrimPrefix(arg, "-t")
+		default:
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			timeout = seconds
+		}
+	}
+	return timeout
+}
+
 func init() {
 	AddCommandBuilder(newDownCommand)
 }
@@ -31,4 +31,14 @@ down
 			assert.Equal(t, expOut, stdout)
 		})
 	})
+
+	t.Run("timeout", func(t *testing.T) {
+		assert.Equal(t, 10, downTimeout([]string{"-v"}), "default")
+		assert.Equal(t, 3, downTimeout([]string{"-v", "-t", "3"}))
+		assert.Equal(t, 4, downTimeout([]string{"-t4"}))
+		assert.Equal(t, 5, downTimeout([]string{"--timeout", "5", "--rmi=local"}))
+		assert.Equal(t, 6, downTimeout([]string{"--timeout=6"}))
+		assert.Equal(t, 10, downTimeout([]string{"-t", "x"}), "invalid")
+		assert.Equal(t, 10, downTimeout([]string{"--", "-t", "1"}), "not a flag")
+	})
 }
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/proc"
+)
+
+// envFormatters write a var in each of the formats "muss env" supports.
+var envFormatters = map[string]func(name, value string) string{
+	"sh": func(name, value string) string {
+		value = strings.Replace(value, "'", `'\''`, -1)
+		return fmt.Sprintf("export %s='%s'\n", name, value)
+	},
+	"fish": func(name, value string) string {
+		value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+		return fmt.Sprintf("set -gx %s '%s';\n", name, value)
+	},
+	"dotenv": func(name, value string) string {
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`).Replace(value)
+		return fmt.Sprintf("%s=\"%s\"\n", name, value)
+	},
+}
+
+func newEnvCommand(cfg *config.ProjectConfig) *cobra.Command {
+	format := "sh"
+	mask := false
+	var vars []string
+	service := ""
+
+	var cmd = &cobra.Command{
+		Use:   "env",
+		Short: "Print the environment muss loads",
+		Long: `Print the environment that muss loads for commands
+(COMPOSE_* vars and the vars set by env commands and secrets).
+
+With --service the "env_file" and "environment" of that compose service
+are resolved and included as well.
+
+Useful for loading the environment into a shell, direnv, or an IDE:
+
+  eval "$(muss env)"
+  muss env --format fish | source
+  muss env --format dotenv --service app > .env.app`,
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// Check before loading (and maybe prompting for) any secrets.
+			if err := checkEnvFormat(format); err != nil {
+				return err
+			}
+			return configSavePreRun(cfg)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env := cfg.LoadedEnv()
+			if service != "" {
+				serviceEnv, err := cfg.ServiceEnv(service)
+				if err != nil {
+					return NewQuietError(err)
+				}
+				for k, v := range serviceEnv {
+					env[k] = v
+				}
+			}
+
+			if len(vars) > 0 {
+				selected := make(map[string]string, len(vars))
+				for _, name := range vars {
+					if value, ok := env[name]; ok {
+						selected[name] = value
+					}
+				}
+				env = selected
+			}
+
+			if mask {
+				secrets := config.SecretValues()
+				for k, v := range env {
+					env[k] = string(proc.Redact([]byte(v), secrets))
+				}
+			}
+
+			if format != "json" {
+				skipInvalidNames(cmd.ErrOrStderr(), format, env)
+			}
+
+			return writeEnv(cmd.OutOrStdout(), format, env)
+		},
+	}
+
+	cmd.Flags().SortFlags = false
+	cmd.Flags().StringVarP(&format, "format", "f", format, "Output format: sh, fish, dotenv, or json.")
+	cmd.Flags().BoolVarP(&mask, "mask", "", false, "Mask secret values.")
+	cmd.Flags().StringSliceVarP(&vars, "vars", "", nil, "Only print these vars (comma separated).")
+	cmd.Flags().StringVarP(&service, "service", "s", "", "Include the environment of this compose service.")
+
+	return cmd
+}
+
+// reEnvName matches names that shells (and dotenv files) accept.
+var reEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func checkEnvFormat(format string) error {
+	if _, ok := envFormatters[format]; !ok && format != "json" {
+		return fmt.Errorf("unknown format '%s' (expected one of dotenv, fish, json, sh)", format)
+	}
+	return nil
+}
+
+// skipInvalidNames removes (with a warning) the vars whose names
+// can't be written in the format (compose accepts almost anything in
+// env_file and environment).
+func skipInvalidNames(w io.Writer, format string, env map[string]string) {
+	for _, name := range sortedEnvNames(env) {
+		if !reEnvName.MatchString(name) {
+			fmt.Fprintf(w, "Warning: skipping %q (not a valid variable name for %s)\n", name, format)
+			delete(env, name)
+		}
+	}
+}
+
+func sortedEnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeEnv writes the env (sorted by name) in the format.
+func writeEnv(w io.Writer, format string, env map[string]string) error {
+	if err := checkEnvFormat(format); err != nil {
+		return err
+	}
+
+	if format == "json" {
+		content, err := json.MarshalIndent(env, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", content)
+		return err
+	}
+
+	formatter := envFormatters[format]
+	for _, name := range sortedEnvNames(env) {
+		if _, err := io.WriteString(w, formatter(name, env[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	AddCommandBuilder(newEnvCommand)
+}
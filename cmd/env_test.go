@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func newEnvTestConfig(t *testing.T) *config.ProjectConfig {
+	return newTestConfig(t, map[string]interface{}{
+		"project_name": "envproj",
+		"secret_commands": map[string]interface{}{
+			"show": map[string]interface{}{
+				"exec":  []interface{}{"echo"},
+				"cache": "none",
+			},
+		},
+		"service_definitions": []map[string]interface{}{
+			{
+				"name": "app",
+				"configs": map[string]interface{}{
+					"sole": map[string]interface{}{
+						"secrets": map[string]interface{}{
+							"MUSS_TEST_ENV_TOKEN": map[string]interface{}{
+								"show": []interface{}{"it's-secret"},
+							},
+						},
+						"services": map[string]interface{}{
+							"app": map[string]interface{}{
+								"image":    "app",
+								"env_file": "app.env",
+								"environment": []interface{}{
+									"MUSS_TEST_ENV_TOKEN",
+									"GREETING=hello ${MUSS_TEST_ENV_NAME:-world}",
+									"PRICE=$$5",
+									"NOT.A-NAME=skipped",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestEnvCommand(t *testing.T) {
+	for _, name := range []string{"COMPOSE_PROJECT_NAME", "COMPOSE_FILE", "MUSS_TEST_ENV_TOKEN", "MUSS_TEST_ENV_NAME"} {
+		if value, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, value)
+		} else {
+			defer os.Unsetenv(name)
+		}
+		os.Unsetenv(name)
+	}
+
+	run := func(args ...string) (stdout, stderr string, err error) {
+		t.Helper()
+		testutil.WithTempDir(t, func(dir string) {
+			testutil.WriteFile(t, "app.env", "# from the file\nFROM_FILE=\"a $b\"\nGREETING=overridden\n")
+
+			// Save the config (which loads the env) like the PreRun does.
+			cfg := newEnvTestConfig(t)
+			if err := cfg.Save(); err != nil {
+				t.Fatalf("error saving config: %s", err)
+			}
+			defer os.Unsetenv("COMPOSE_PROJECT_NAME")
+			defer os.Unsetenv("MUSS_TEST_ENV_TOKEN")
+
+			stdout, stderr, err = runTestCommand(cfg, append([]string{"env"}, args...))
+		})
+		return
+	}
+
+	t.Run("sh", func(t *testing.T) {
+		stdout, stderr, err := run()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, `export COMPOSE_PROJECT_NAME='envproj'
+export MUSS_TEST_ENV_TOKEN='it'\''s-secret'
+`, stdout)
+	})
+
+	t.Run("formats", func(t *testing.T) {
+		stdout, _, err := run("--format", "fish", "--vars", "MUSS_TEST_ENV_TOKEN")
+		assert.Nil(t, err)
+		assert.Equal(t, "set -gx MUSS_TEST_ENV_TOKEN 'it\\'s-secret';\n", stdout)
+
+		stdout, _, err = run("--format", "dotenv")
+		assert.Nil(t, err)
+		assert.Equal(t, "COMPOSE_PROJECT_NAME=\"envproj\"\nMUSS_TEST_ENV_TOKEN=\"it's-secret\"\n", stdout)
+
+		stdout, _, err = run("--format", "json", "--vars", "COMPOSE_PROJECT_NAME,NOPE")
+		assert.Nil(t, err)
+		assert.Equal(t, "{\n  \"COMPOSE_PROJECT_NAME\": \"envproj\"\n}\n", stdout)
+
+		_, _, err = run("--format", "xml")
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "unknown format 'xml' (expected one of dotenv, fish, json, sh)", err.Error())
+		}
+	})
+
+	t.Run("mask", func(t *testing.T) {
+		stdout, _, err := run("--mask", "--vars", "MUSS_TEST_ENV_TOKEN")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "export MUSS_TEST_ENV_TOKEN='********'\n", stdout)
+	})
+
+	t.Run("service", func(t *testing.T) {
+		stdout, stderr, err := run("--service", "app", "--format", "dotenv")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Warning: skipping \"NOT.A-NAME\" (not a valid variable name for dotenv)\n", stderr)
+		assert.Equal(t, `COMPOSE_PROJECT_NAME="envproj"
+FROM_FILE="a \$b"
+GREETING="hello world"
+MUSS_TEST_ENV_TOKEN="it's-secret"
+PRICE="\$5"
+`, stdout)
+
+		stdout, stderr, err = run("--service", "app", "--format", "json", "--vars", "NOT.A-NAME")
+		assert.Nil(t, err)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, "{\n  \"NOT.A-NAME\": \"skipped\"\n}\n", stdout, "json can have any name")
+
+		_, _, err = run("--service", "nope")
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "service 'nope' not found", err.Error())
+		}
+	})
+
+	t.Run("format checked first", func(t *testing.T) {
+		testutil.WithTempDir(t, func(dir string) {
+			cfg := newEnvTestConfig(t)
+			cmd, _, _ := NewRootCommand(cfg).Find([]string{"env"})
+			cmd.Flags().Set("format", "xml")
+
+			err := cmd.PreRunE(cmd, nil)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "unknown format 'xml' (expected one of dotenv, fish, json, sh)", err.Error())
+			}
+			_, ok := os.LookupEnv("MUSS_TEST_ENV_TOKEN")
+			assert.False(t, ok, "secrets not loaded")
+			_, err = os.Stat(cfg.ComposeFilePath())
+			assert.True(t, os.IsNotExist(err), "config not saved")
+		})
+	})
+}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"gerrit.instructure.com/muss/config"
@@ -35,6 +39,11 @@ Options:
 		PreRunE:            configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
 
+			if service, index := execTarget(args); index != 0 {
+				if err := checkContainerIndex(cfg, service, index); err != nil {
+					return err
+				}
+			}
 			return dockerComposeExec(cmd, args)
 		},
 	}
@@ -45,6 +54,56 @@ Options:
 	return cmd
 }
 
+// execTarget returns the service and the value of --index
+// (or 0 if it was not given).
+func execTarget(args []string) (string, int) {
+	index := 0
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--index":
+			if i+1 < len(args) {
+				i++
+				index, _ = strconv.Atoi(args[i])
+			}
+		case strings.HasPrefix(arg, "--index="):
+			index, _ = strconv.Atoi(strings.TrimPrefix(arg, "--index="))
+		case arg == "-u" || arg == "--user" || arg == "-e" || arg == "--env" || arg == "-w" || arg == "--workdir":
+			// Skip the value.
+			i++
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1], index
+			}
+			return "", index
+		case !strings.HasPrefix(arg, "-"):
+			return arg, index
+		}
+	}
+	return "", index
+}
+
+// checkContainerIndex returns an error if the engine is available and the
+// service doesn't have a container with that index (so that we can say so
+// rather than compose failing with less detail).
+func checkContainerIndex(cfg *config.ProjectConfig, service string, index int) error {
+	if service == "" {
+		return nil
+	}
+	if index < 1 {
+		return fmt.Errorf("Index %d not found for service %s", index, service)
+	}
+	c, err := engineClient().FindContainer(composeProject(cfg), service, index)
+	if err != nil {
+		// Let compose report any problems.
+		return nil
+	}
+	if c == nil {
+		return fmt.Errorf("Index %d not found for service %s", index, service)
+	}
+	return nil
+}
+
 func init() {
 	AddCommandBuilder(newExecCommand)
 }
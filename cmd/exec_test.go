@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"gerrit.instructure.com/muss/proc"
+	"gerrit.instructure.com/muss/testutil"
 )
 
 func TestExecCommand(t *testing.T) {
@@ -54,5 +55,40 @@ func TestExecCommand(t *testing.T) {
 
 			assert.Equal(t, exp, proc.LastExecArgv)
 		})
+
+		t.Run("index", func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"project_name": "proj"})
+			withFakeEngine(t, []testutil.FakeContainer{
+				{ID: "svc1", Project: "proj", Service: "svc", Number: 1},
+				{ID: "svc2", Project: "proj", Service: "svc", Number: 2},
+			}, func(t *testing.T) {
+				proc.LastExecArgv = nil
+				_, _, err := runTestCommand(cfg, []string{"exec", "-u", "root", "--index", "3", "svc", "cmd"})
+				assert.Equal(t, "Index 3 not found for service svc", err.Error())
+				assert.Nil(t, proc.LastExecArgv, "not run")
+
+				_, _, err = runTestCommand(cfg, []string{"exec", "--index=2", "svc", "cmd"})
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"docker-compose", "exec", "--index=2", "svc", "cmd"}, proc.LastExecArgv)
+			})
+		})
 	})
 }
+
+func TestExecTarget(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		service string
+		index   int
+	}{
+		{[]string{"svc", "cmd", "--index", "2"}, "svc", 0},
+		{[]string{"-T", "--index", "2", "svc", "cmd"}, "svc", 2},
+		{[]string{"-u", "root", "--index=3", "-e", "K=V", "-w", "/dir", "svc"}, "svc", 3},
+		{[]string{"--index=2", "--", "svc"}, "svc", 2},
+		{[]string{"-d"}, "", 0},
+	} {
+		service, index := execTarget(tc.args)
+		assert.Equal(t, tc.service, service, tc.args)
+		assert.Equal(t, tc.index, index, tc.args)
+	}
+}
@@ -1,24 +1,60 @@
 package cmd
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	"gerrit.instructure.com/muss/backend"
 	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/engine"
 	"gerrit.instructure.com/muss/proc"
-	"gerrit.instructure.com/muss/term"
 )
 
-var dc = "docker-compose"
+// backendName is the backend configured for the project (if any).
+var backendName string
+
+// activeBackend is chosen the first time it is needed
+// (since detecting it may run commands).
+var activeBackend backend.Backend
+
+// composeBackend returns the backend that runs compose and container commands.
+func composeBackend() backend.Backend {
+	if activeBackend == nil {
+		b, err := backend.Select(backendName)
+		if err != nil {
+			// Unknown names are reported when the config is loaded.
+			b = backend.Detect()
+		}
+		activeBackend = b
+	}
+	return activeBackend
+}
+
+func setBackend(cfg *config.ProjectConfig) {
+	backendName = ""
+	if cfg != nil {
+		backendName = cfg.Backend
+	}
+	activeBackend = nil
+}
+
+func command(argv []string) *exec.Cmd {
+	return exec.Command(argv[0], argv[1:]...)
+}
 
 func configSavePreRun(cfg *config.ProjectConfig) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, argv []string) error {
+		setNonInteractive(cmd)
 		err := cfg.Save()
 		for _, w := range cfg.Warnings {
 			fmt.Fprintln(cmd.ErrOrStderr(), w)
@@ -27,14 +63,61 @@ func configSavePreRun(cfg *config.ProjectConfig) func(*cobra.Command, []string)
 	}
 }
 
+// setNonInteractive exports MUSS_NONINTERACTIVE if --non-interactive was
+// passed so that config (and any child processes) will respect it.
+func setNonInteractive(cmd *cobra.Command) {
+	if flag := cmd.Flags().Lookup("non-interactive"); flag != nil && flag.Changed && flag.Value.String() == "true" {
+		os.Setenv("MUSS_NONINTERACTIVE", "1")
+	}
+}
+
 func cmdDelegator(cmd *cobra.Command) *proc.Delegator {
 	return (&proc.Delegator{
-		Stdin:  cmd.InOrStdin(),
-		Stdout: cmd.OutOrStdout(),
-		Stderr: cmd.ErrOrStderr(),
+		Stdin:       cmd.InOrStdin(),
+		Stdout:      cmd.OutOrStdout(),
+		Stderr:      cmd.ErrOrStderr(),
+		GracePeriod: shutdownGracePeriod(cmd),
 	})
 }
 
+// gracePeriodMargin is added to the docker-compose shutdown timeout
+// (which it uses for stopping each container) to allow for its own work.
+const gracePeriodMargin = 10 * time.Second
+
+// shutdownGracePeriod returns how long to wait for docker-compose to exit
+// once it is signaled based on the --timeout of commands that have one
+// (other commands wait until Ctrl-C is pressed again).
+func shutdownGracePeriod(cmd *cobra.Command) time.Duration {
+	flag := cmd.Flags().Lookup("timeout")
+	if flag == nil || flag.Value.Type() != "int" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(flag.Value.String())
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return gracePeriodFromTimeout(seconds)
+}
+
+func gracePeriodFromTimeout(seconds int) time.Duration {
+	return time.Duration(seconds)*time.Second + gracePeriodMargin
+}
+
+// redactOutput masks the values of any loaded secrets
+// in the output of the delegated commands.
+func redactOutput(d *proc.Delegator) error {
+	values := config.SecretValues()
+	if len(values) == 0 {
+		return nil
+	}
+	if err := d.FilterStdout(proc.NewRedactFilter(values)); err != nil {
+		return err
+	}
+	// Keep the colors of the filtered output (when it ends up on a terminal).
+	d.UsePTY = true
+	return d.FilterStderr(proc.NewRedactFilter(values))
+}
+
 // DelegateCmd runs with a delegator made from a `cobra.Cmd`.
 func DelegateCmd(cmd *cobra.Command, commands ...*exec.Cmd) (err error) {
 	return cmdDelegator(cmd).Delegate(commands...)
@@ -96,31 +179,238 @@ func (f flagDumper) fromCmd(cmd *cobra.Command) []string {
 	return args
 }
 
-func dockerCmd(args ...string) *exec.Cmd {
-	return exec.Command("docker", args...)
+func containerCmd(args ...string) *exec.Cmd {
+	return command(composeBackend().Container(args...))
 }
 
-func dockerComposeArgs(cmd *cobra.Command, args []string) []string {
-	flags := (flagDumper{}).fromCmd(cmd)
+func composeCmd(args ...string) *exec.Cmd {
+	return command(composeBackend().Compose(args...))
+}
+
+// expandServices replaces service selectors (like "@backend") in the args
+// with the names of the services they select.
+func expandServices(cfg *config.ProjectConfig, args []string) ([]string, error) {
+	services, err := cfg.ExpandServices(args)
+	return services, QuietErrorOrNil(err)
+}
+
+// expandServiceArgs expands the selectors in the services that follow the
+// options in args (for commands that don't parse their flags).
+// valueFlags are the options that take a separate value.
+func expandServiceArgs(cfg *config.ProjectConfig, args []string, valueFlags ...string) ([]string, error) {
+	takesValue := make(map[string]bool, len(valueFlags))
+	for _, flag := range valueFlags {
+		takesValue[flag] = true
+	}
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if takesValue[arg] {
+			i++
+		}
+	}
+	if i >= len(args) {
+		return args, nil
+	}
+	services, err := expandServices(cfg, args[i:])
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, args[:i]...), services...), nil
+}
+
+// dockerComposeArgs returns the args for the compose command named by cmd
+// including the flags that were set (adapted to what the backend supports).
+func dockerComposeArgs(cmd *cobra.Command, args []string) ([]string, error) {
+	flags, warnings, err := backend.CheckFlags(composeBackend(), cmd.CalledAs(), (flagDumper{}).fromCmd(cmd))
+	for _, w := range warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", w)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	cmdargs := make([]string, 1, 1+len(flags)+len(args))
 	cmdargs[0] = cmd.CalledAs()
 	cmdargs = append(cmdargs, flags...)
 	cmdargs = append(cmdargs, args...)
 
-	return cmdargs
+	return cmdargs, nil
 }
 
-func dockerComposeCmd(cmd *cobra.Command, args []string) *exec.Cmd {
-	return exec.Command(dc, dockerComposeArgs(cmd, args)...)
+func dockerComposeCmd(cmd *cobra.Command, args []string) (*exec.Cmd, error) {
+	cmdargs, err := dockerComposeArgs(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+	return composeCmd(cmdargs...), nil
 }
 
 func dockerComposeExec(cmd *cobra.Command, args []string) error {
-	return proc.Exec(append([]string{dc}, dockerComposeArgs(cmd, args)...))
+	cmdargs, err := dockerComposeArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+	return proc.Exec(composeBackend().Compose(cmdargs...))
+}
+
+// changedSecretVars and recordSecretVars can be replaced in tests.
+var (
+	changedSecretVars = config.ChangedSecretVars
+	recordSecretVars  = config.RecordSecretVars
+)
+
+// handleChangedSecrets finds running services that use secrets whose values
+// have changed since the last run and either warns about them
+// or recreates them.
+// The new values are only recorded once nothing is running with the old ones
+// so the warning is repeated until the services are recreated.
+// It returns the services that are still running with the old values
+// (the caller can record the new values with recordSecretVars(nil)
+// once it has recreated them).
+func handleChangedSecrets(cmd *cobra.Command, cfg *config.ProjectConfig, recreate bool) ([]string, error) {
+	stderr := cmd.ErrOrStderr()
+	warnRecord := func(err error) {
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: unable to record secret values: %s\n", err)
+		}
+	}
+
+	vars, err := changedSecretVars()
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: unable to check for changed secrets: %s\n", err)
+		return nil, nil
+	}
+	if len(vars) == 0 {
+		warnRecord(recordSecretVars(nil))
+		return nil, nil
+	}
+
+	services, err := cfg.ServicesUsingVars(vars)
+	if err != nil {
+		return nil, err
+	}
+	services, err = runningServices(cfg, services)
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: unable to check for services using changed secrets: %s\n", err)
+		return nil, nil
+	}
+	if len(services) == 0 {
+		warnRecord(recordSecretVars(nil))
+		return nil, nil
+	}
+
+	// Record any other new values but keep the old ones for the changed vars.
+	warnRecord(recordSecretVars(vars))
+
+	if !recreate {
+		fmt.Fprintf(stderr, "Secrets have changed (%s) for running services: %s\n", strings.Join(vars, ", "), strings.Join(services, ", "))
+		fmt.Fprintln(stderr, "Use --recreate-changed to recreate them with the new values.")
+		return services, nil
+	}
+
+	fmt.Fprintf(stderr, "Recreating services with changed secrets: %s\n", strings.Join(services, ", "))
+	err = DelegateCmd(
+		cmd,
+		composeCmd(append([]string{"up", "--detach", "--no-deps", "--force-recreate"}, services...)...),
+	)
+	if err != nil {
+		return services, err
+	}
+	warnRecord(recordSecretVars(nil))
+	return nil, nil
+}
+
+// engineSocket can be replaced in tests.
+var engineSocket = func() string {
+	return engine.DefaultSocket(composeBackend().Container()[0])
 }
 
-func dockerContainerID(service string) (string, error) {
-	cid, _, err := proc.CmdOutput("docker-compose", "ps", "-q", service)
+func engineClient() *engine.Client {
+	return engine.NewClient(engineSocket())
+}
+
+// composeProject returns the name of the compose project
+// (which is used to label its containers).
+func composeProject(cfg *config.ProjectConfig) string {
+	if name := os.Getenv("COMPOSE_PROJECT_NAME"); name != "" {
+		return name
+	}
+	if cfg != nil && cfg.ProjectName != "" {
+		return cfg.ProjectName
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return backend.DefaultProjectName(composeBackend(), wd)
+}
+
+// runningServices returns the services that have running containers.
+// It returns an error if it can't tell which are running.
+func runningServices(cfg *config.ProjectConfig, services []string) ([]string, error) {
+	running := make(map[string]bool)
+
+	containers, err := engineClient().Containers(engine.Filter{Project: composeProject(cfg), Running: true})
+	switch {
+	case errors.Is(err, engine.ErrUnavailable):
+		flags, _, err := backend.CheckFlags(composeBackend(), "ps", []string{"--services", "--filter=status=running"})
+		if err != nil {
+			return nil, err
+		}
+		stdout, _, err := proc.CmdOutput(composeBackend().Compose(append([]string{"ps"}, flags...)...)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(stdout, "\n") {
+			running[name] = true
+		}
+	case err != nil:
+		return nil, err
+	default:
+		for _, c := range containers {
+			running[c.Service()] = true
+		}
+	}
+
+	result := make([]string, 0, len(services))
+	for _, svc := range services {
+		if running[svc] {
+			result = append(result, svc)
+		}
+	}
+	return result, nil
+}
+
+// containerID returns the id of the numbered container (starting at 1)
+// of the service.  It asks the engine for the container with that number
+// and falls back to listing them with compose if the engine isn't available.
+func containerID(cfg *config.ProjectConfig, service string, index int) (string, error) {
+	if index < 1 {
+		return "", fmt.Errorf("Index %d not found for service %s", index, service)
+	}
+	c, err := engineClient().FindContainer(composeProject(cfg), service, index)
+	if errors.Is(err, engine.ErrUnavailable) {
+		return composeContainerID(service, index)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get container id for %s: %w", service, err)
+	}
+	if c == nil {
+		return "", fmt.Errorf("Index %d not found for service %s", index, service)
+	}
+	return c.ID, nil
+}
+
+func composeContainerID(service string, index int) (string, error) {
+	cid, _, err := proc.CmdOutput(composeBackend().Compose("ps", "-q", service)...)
 
 	errorMessage := fmt.Sprintf("failed to get container id for %s", service)
 
@@ -132,22 +422,41 @@ func dockerContainerID(service string) (string, error) {
 		return "", fmt.Errorf("%s", errorMessage)
 	}
 
-	return cid, nil
+	lines := strings.Split(cid, "\n")
+	if len(lines) < index {
+		return "", fmt.Errorf("Index %d not found for service %s", index, service)
+	}
+
+	return lines[index-1], nil
 }
 
+// dcErrorFilter passes docker-compose errors through and then adds messages
+// with suggestions for fixing errors it recognizes.
 type dcErrorFilter struct {
-	*proc.Pipe
-	cfg          *config.ProjectConfig
-	messages     []string
-	readerDoneCh chan bool
+	*proc.LineFilter
+	cfg      *config.ProjectConfig
+	messages []string
+
+	// Collect registries that have login errors so that at the end we only print
+	// once for each registry, and only print "unknown registry" if we couldn't
+	// identify any specific ones.
+	// The output for `dc pull` with a 403 will show the error once with
+	// each service name and then print them all again without.
+	unknownRegistryLogin bool
+	registriesForLogin   []string
+	lastRegistry         string
+	lastService          string
 }
 
 func newDCErrorFilter(cfg *config.ProjectConfig) proc.StreamFilter {
-	return &dcErrorFilter{
-		cfg:          cfg,
-		readerDoneCh: make(chan bool, 1),
-		Pipe:         &proc.Pipe{},
+	f := &dcErrorFilter{
+		cfg:                cfg,
+		messages:           make([]string, 0),
+		registriesForLogin: make([]string, 0),
 	}
+	f.LineFilter = proc.NewLineFilter(f.scanLine)
+	f.OnStop = f.printMessages
+	return f
 }
 
 var rePullingImage = regexp.MustCompile(`^Pulling (\S+) \((?:https?://)?([^/]+)`)
@@ -155,76 +464,63 @@ var reGetNoBasicAuth = regexp.MustCompile(`Get (?:https?://)?([^/]+)\S+?: no bas
 var reParsingHTTP403 = regexp.MustCompile(`(?:Service '(\S+)' failed to build:\s+|for (\S+)\s+)?error parsing HTTP 403 response body: unexpected end of JSON input: ""`)
 var reNoStoredCredential = regexp.MustCompile(`No stored credential for ([^"]+)`)
 
-func (f *dcErrorFilter) Start(doneCh chan bool) {
-	cfg := f.cfg
-	reader := f.Reader()
-	writer := f.Writer()
-	f.messages = make([]string, 0)
-
-	// Collect registries that have login errors so that at the end we only print
-	// once for each registry, and only print "unknown registry" if we couldn't
-	// identify any specific ones.
-	// The output for `dc pull` with a 403 will show the error once with
-	// each service name and then print them all again without.
-	unknownRegistryLogin := false
-	registriesForLogin := make([]string, 0)
-
-	var lastRegistry, lastService string
+// scanLine looks for errors and passes the line along (to STDERR).
+func (f *dcErrorFilter) scanLine(line []byte) ([]byte, error) {
+	length := len(line)
+	// Only do the regexp matching for full lines.
+	if length == 0 || line[length-1] != '\n' {
+		return line, nil
+	}
 
-	go func() {
-		if f, ok := reader.(io.ReadCloser); ok {
-			defer f.Close()
+	if match := rePullingImage.FindSubmatch(line); match != nil {
+		f.lastService = string(match[1])
+		f.lastRegistry = string(match[2])
+	} else if match := reNoStoredCredential.FindSubmatch(line); match != nil {
+		f.registriesForLogin = append(f.registriesForLogin, string(match[1]))
+	} else if match := reGetNoBasicAuth.FindSubmatch(line); match != nil {
+		f.registriesForLogin = append(f.registriesForLogin, string(match[1]))
+	} else if match := reParsingHTTP403.FindSubmatch(line); match != nil {
+		registry := f.lastRegistry
+		if len(match[1]) > 0 {
+			registry = registryFromImage(dcImageForService(f.cfg, string(match[1])))
+		} else if len(match[2]) > 0 {
+			registry = registryFromImage(dcImageForService(f.cfg, string(match[2])))
+		} else if f.lastRegistry == "" && f.lastService != "" {
+			registry = registryFromImage(dcImageForService(f.cfg, f.lastService))
 		}
-		scanner := bufio.NewScanner(reader)
-		scanner.Split(term.ScanLinesOrAnsiMovements)
-		for scanner.Scan() {
-			line := scanner.Bytes()
-			length := len(line)
-			// Only do the regexp matching for full lines.
-			if length > 0 && line[length-1] == '\n' {
-
-				if match := rePullingImage.FindSubmatch(line); match != nil {
-					lastService = string(match[1])
-					lastRegistry = string(match[2])
-				} else if match := reNoStoredCredential.FindSubmatch(line); match != nil {
-					registriesForLogin = append(registriesForLogin, string(match[1]))
-				} else if match := reGetNoBasicAuth.FindSubmatch(line); match != nil {
-					registriesForLogin = append(registriesForLogin, string(match[1]))
-				} else if match := reParsingHTTP403.FindSubmatch(line); match != nil {
-					registry := lastRegistry
-					if len(match[1]) > 0 {
-						registry = registryFromImage(dcImageForService(cfg, string(match[1])))
-					} else if len(match[2]) > 0 {
-						registry = registryFromImage(dcImageForService(cfg, string(match[2])))
-					} else if lastRegistry == "" && lastService != "" {
-						registry = registryFromImage(dcImageForService(cfg, lastService))
-					}
-					if registry == "" {
-						unknownRegistryLogin = true
-					} else {
-						registriesForLogin = append(registriesForLogin, registry)
-					}
-				} else {
-					lastService = ""
-					lastRegistry = ""
-				}
-
-			}
-			// Propagate to STDERR.
-			writer.Write(line)
+		if registry == "" {
+			f.unknownRegistryLogin = true
+		} else {
+			f.registriesForLogin = append(f.registriesForLogin, registry)
 		}
+	} else {
+		f.lastService = ""
+		f.lastRegistry = ""
+	}
 
-		loginMessageFormat := "You may need to login to %s"
-		if len(registriesForLogin) > 0 {
-			for _, registry := range registriesForLogin {
-				f.messages = appendOnce(f.messages, fmt.Sprintf(loginMessageFormat, registry))
-			}
-		} else if unknownRegistryLogin {
-			f.messages = appendOnce(f.messages, fmt.Sprintf(loginMessageFormat, "your docker registry"))
+	return line, nil
+}
+
+// printMessages adds suggestions after the errors.
+func (f *dcErrorFilter) printMessages(writer io.Writer) error {
+	loginMessageFormat := "You may need to login to %s"
+	if len(f.registriesForLogin) > 0 {
+		for _, registry := range f.registriesForLogin {
+			f.messages = appendOnce(f.messages, fmt.Sprintf(loginMessageFormat, registry))
 		}
+	} else if f.unknownRegistryLogin {
+		f.messages = appendOnce(f.messages, fmt.Sprintf(loginMessageFormat, "your docker registry"))
+	}
 
-		f.readerDoneCh <- true
-	}()
+	if len(f.messages) == 0 {
+		return nil
+	}
+	// Print a spacer line to separate pass-through errors from our messages.
+	fmt.Fprintln(writer, "")
+	for _, msg := range f.messages {
+		fmt.Fprintln(writer, msg)
+	}
+	return nil
 }
 
 func appendOnce(slice []string, add string) []string {
@@ -268,18 +564,3 @@ func registryFromImage(image string) string {
 	}
 	return ""
 }
-
-func (f *dcErrorFilter) Stop() {
-	// Wait until the filter is complete.
-	<-f.readerDoneCh
-
-	if len(f.messages) == 0 {
-		return
-	}
-	writer := f.Writer()
-	// Print a spacer line to separate pass-through errors from our messages.
-	fmt.Fprintln(writer, "")
-	for _, msg := range f.messages {
-		fmt.Fprintln(writer, msg)
-	}
-}
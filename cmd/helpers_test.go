@@ -5,8 +5,13 @@ import (
 	"path"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/backend"
 	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
 )
 
 // helpers
@@ -19,6 +24,19 @@ func init() {
 		panic("Failed to get current dir: " + err.Error())
 	}
 	testbin = path.Join(cwd, "..", "testdata", "bin")
+
+	// Don't record secret hashes in the user's cache dir.
+	changedSecretVars = func() ([]string, error) { return nil, nil }
+	recordSecretVars = func([]string) error { return nil }
+	// Don't cache versions of the test binaries either.
+	backend.VersionCacheFile = ""
+	// Don't talk to a real container engine.
+	engineSocket = func() string { return "" }
+}
+
+func TestMain(m *testing.M) {
+	testutil.RunFakeBin()
+	os.Exit(m.Run())
 }
 
 func newTestConfig(t *testing.T, cfgMap map[string]interface{}) *config.ProjectConfig {
@@ -29,6 +47,38 @@ func newTestConfig(t *testing.T, cfgMap map[string]interface{}) *config.ProjectC
 	return cfg
 }
 
+// newSecretTestConfig returns a config with a secret that sets the var
+// to the value (which is loaded so that it will be masked).
+func newSecretTestConfig(t *testing.T, varname, value string) *config.ProjectConfig {
+	cfg := newTestConfig(t, map[string]interface{}{
+		"secret_commands": map[string]interface{}{
+			"show": map[string]interface{}{
+				"exec":  []interface{}{"echo"},
+				"cache": "none",
+			},
+		},
+		"service_definitions": []map[string]interface{}{
+			{
+				"name": "app",
+				"configs": map[string]interface{}{
+					"sole": map[string]interface{}{
+						"secrets": map[string]interface{}{
+							varname: map[string]interface{}{"show": []interface{}{value}},
+						},
+					},
+				},
+			},
+		},
+	})
+	if _, err := cfg.ComposeConfig(); err != nil {
+		t.Fatalf("unexpected compose config error: %s", err)
+	}
+	if err := cfg.LoadEnv(); err != nil {
+		t.Fatalf("error loading env: %s", err)
+	}
+	return cfg
+}
+
 func runTestCommand(cfg *config.ProjectConfig, args []string) (string, string, error) {
 	var stdout, stderr strings.Builder
 
@@ -36,7 +86,7 @@ func runTestCommand(cfg *config.ProjectConfig, args []string) (string, string, e
 		cfg, _ = config.NewConfigFromMap(nil)
 	}
 
-	cmd := NewRootCommand(cfg)
+	cmd := newRootCommand(cfg, args)
 	cmd.SetOut(&stdout)
 	cmd.SetErr(&stderr)
 	cmd.SetArgs(args)
@@ -56,3 +106,135 @@ func withTestPath(t *testing.T, f func(*testing.T)) {
 	defer os.Setenv("PATH", path)
 	t.Run("with test path", f)
 }
+
+func withFakeEngine(t *testing.T, containers []testutil.FakeContainer, f func(*testing.T)) {
+	socket, stop := testutil.FakeEngine(t, containers)
+	defer stop()
+	orig := engineSocket
+	engineSocket = func() string { return socket }
+	defer func() { engineSocket = orig }()
+	t.Run("with fake engine", f)
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	cfg, _ := config.NewConfigFromMap(nil)
+	root := NewRootCommand(cfg)
+
+	for name, expected := range map[string]time.Duration{
+		"stop": 20 * time.Second,
+		"up":   20 * time.Second,
+		"logs": 0,
+	} {
+		cmd, _, err := root.Find([]string{name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, shutdownGracePeriod(cmd), name)
+	}
+
+	cmd, _, _ := root.Find([]string{"restart"})
+	cmd.Flags().Set("timeout", "3")
+	assert.Equal(t, 13*time.Second, shutdownGracePeriod(cmd), "timeout plus margin")
+	assert.Equal(t, 13*time.Second, cmdDelegator(cmd).GracePeriod)
+}
+
+func TestRedactOutputUsesPTY(t *testing.T) {
+	cmd, _, _ := NewRootCommand(newTestConfig(t, nil)).Find([]string{"logs"})
+	assert.False(t, cmdDelegator(cmd).UsePTY, "not for unfiltered commands")
+
+	cfg := newSecretTestConfig(t, "MUSS_TEST_PTY_SECRET", "pty-secret")
+	defer os.Unsetenv("MUSS_TEST_PTY_SECRET")
+
+	cmd, _, _ = NewRootCommand(cfg).Find([]string{"logs"})
+	d := cmdDelegator(cmd)
+	assert.Nil(t, redactOutput(d))
+	assert.True(t, d.UsePTY, "for filtered output")
+}
+
+func TestRunningServices(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"project_name": "proj"})
+	withFakeEngine(t, []testutil.FakeContainer{
+		{ID: "web1", Project: "proj", Service: "web", Number: 1},
+		{ID: "db1", Project: "proj", Service: "db", Number: 1, State: "exited"},
+		{ID: "cache1", Project: "other", Service: "cache", Number: 1},
+	}, func(t *testing.T) {
+		services, err := runningServices(cfg, []string{"cache", "db", "web"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"web"}, services)
+	})
+
+	t.Run("without engine", func(t *testing.T) {
+		withTestPath(t, func(t *testing.T) {
+			os.Setenv("MUSS_TEST_RUNNING_SERVICES", "web")
+			defer os.Unsetenv("MUSS_TEST_RUNNING_SERVICES")
+
+			services, err := runningServices(cfg, []string{"db", "web"})
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"web"}, services)
+
+			setBackend(&config.ProjectConfig{Backend: "podman-compose"})
+			defer setBackend(nil)
+
+			_, err = runningServices(cfg, []string{"db", "web"})
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "podman-compose does not support ps --filter", err.Error())
+			}
+		})
+	})
+}
+
+// newSelectorsConfig returns a config with service definitions
+// tagged for testing service selectors.
+func newSelectorsConfig(t *testing.T) *config.ProjectConfig {
+	return newTestConfig(t, map[string]interface{}{
+		"service_definitions": []map[string]interface{}{
+			{
+				"name": "app",
+				"tags": []interface{}{"backend"},
+				"configs": map[string]interface{}{
+					"sole": map[string]interface{}{
+						"services": map[string]interface{}{
+							"app":    map[string]interface{}{"image": "app"},
+							"worker": map[string]interface{}{"image": "app"},
+						},
+					},
+				},
+			},
+			{
+				"name": "web",
+				"configs": map[string]interface{}{
+					"sole": map[string]interface{}{
+						"services": map[string]interface{}{
+							"web": map[string]interface{}{"build": "."},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestExpandServiceArgs(t *testing.T) {
+	cfg := newSelectorsConfig(t)
+
+	expand := func(args ...string) []string {
+		t.Helper()
+		expanded, err := expandServiceArgs(cfg, args, "-m", "--build-arg")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return expanded
+	}
+
+	assert.Equal(t, []string{"--pull", "-m", "1g", "--build-arg", "k=v", "app", "worker"},
+		expand("--pull", "-m", "1g", "--build-arg", "k=v", "@backend"))
+	assert.Equal(t, []string{"--no-cache", "--", "web"}, expand("--no-cache", "--", "!@backend"))
+	assert.Equal(t, []string{"--no-cache", "--memory=@backend"}, expand("--no-cache", "--memory=@backend"))
+	assert.Equal(t, []string{"app", "other"}, expand("app", "other"))
+
+	_, err := expandServiceArgs(cfg, []string{"@nope"})
+	if assert.NotNil(t, err) {
+		_, quiet := err.(*QuietError)
+		assert.True(t, quiet)
+	}
+}
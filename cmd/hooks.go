@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gerrit.instructure.com/muss/config"
+)
+
+// hookName returns the name of the hook for the command
+// ("pre_up", "post_secrets_list", etc).
+func hookName(when string, cmd *cobra.Command) string {
+	path := strings.Fields(cmd.CommandPath())
+	return when + "_" + strings.Join(path[1:], "_")
+}
+
+// addHooks wraps each (runnable) subcommand so that the pre and post hooks
+// from the config run around it.
+func addHooks(cfg *config.ProjectConfig, root *cobra.Command) {
+	for _, cmd := range root.Commands() {
+		addHooks(cfg, cmd)
+
+		if cmd.RunE == nil {
+			continue
+		}
+		run := cmd.RunE
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			if err := runHooks(cfg, cmd, hookName("pre", cmd)); err != nil {
+				return err
+			}
+			if err := run(cmd, args); err != nil {
+				return err
+			}
+			return runHooks(cfg, cmd, hookName("post", cmd))
+		}
+	}
+}
+
+// runHooks runs the hooks in order.
+// A failing hook stops the rest (and the command for "pre" hooks) unless its
+// on_failure is "warn" (which prints a warning) or "ignore".
+func runHooks(cfg *config.ProjectConfig, cmd *cobra.Command, name string) error {
+	hooks, err := cfg.HooksFor(name)
+	if err != nil {
+		return err
+	}
+	stderr := cmd.ErrOrStderr()
+	for _, hook := range hooks {
+		fmt.Fprintf(stderr, "muss: running %s hook: %s\n", name, hook)
+
+		err := DelegateCmd(cmd, hookCmd(hook))
+		if err == nil {
+			continue
+		}
+		switch hook.FailureMode() {
+		case config.HookIgnore:
+		case config.HookWarn:
+			fmt.Fprintf(stderr, "Warning: %s hook failed (%s): %s\n", name, hook, err)
+		default:
+			return NewQuietError(fmt.Errorf("%s hook failed (%s): %w", name, hook, err))
+		}
+	}
+	return nil
+}
+
+// hookCmd runs the hook on the host or in its service's container.
+func hookCmd(hook *config.Hook) *exec.Cmd {
+	if hook.Service != "" {
+		return composeCmd(append([]string{"exec", "-T", hook.Service}, hook.Exec...)...)
+	}
+	return exec.Command(hook.Exec[0], hook.Exec[1:]...)
+}
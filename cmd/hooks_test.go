@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func newHooksConfig(t *testing.T, hooks map[string]interface{}) *config.ProjectConfig {
+	t.Helper()
+	cfg, err := config.NewConfigFromMap(map[string]interface{}{"hooks": hooks})
+	if err != nil {
+		t.Fatalf("unexpected config error: %s", err)
+	}
+	return cfg
+}
+
+func hook(exec ...string) map[string]interface{} {
+	list := make([]interface{}, len(exec))
+	for i, arg := range exec {
+		list[i] = arg
+	}
+	return map[string]interface{}{"exec": list}
+}
+
+func TestHookName(t *testing.T) {
+	cmd := NewRootCommand(nil)
+
+	up, _, _ := cmd.Find([]string{"up"})
+	assert.Equal(t, "pre_up", hookName("pre", up))
+
+	secrets := &cobra.Command{Use: "secrets"}
+	list := &cobra.Command{Use: "list"}
+	secrets.AddCommand(list)
+	cmd.AddCommand(secrets)
+	assert.Equal(t, "post_secrets_list", hookName("post", list))
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("pre and post", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose", "migrate", "notify")
+		defer fake.Close()
+
+		cfg := newHooksConfig(t, map[string]interface{}{
+			"pre_pull":  []interface{}{hook("migrate", "--check")},
+			"post_pull": []interface{}{hook("notify", "pulled")},
+		})
+
+		_, stderr, err := runTestCommand(cfg, []string{"pull"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "muss: running pre_pull hook: migrate --check\nmuss: running post_pull hook: notify pulled\n", stderr)
+		calls := fake.Calls("")
+		if assert.Len(t, calls, 3) {
+			assert.Equal(t, "migrate", calls[0].Name)
+			assert.Equal(t, []string{"--check"}, calls[0].Args)
+			assert.Equal(t, "docker-compose", calls[1].Name)
+			assert.Equal(t, []string{"pull"}, calls[1].Args)
+			assert.Equal(t, "notify", calls[2].Name)
+		}
+	})
+
+	t.Run("failing pre hook", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose", "migrate", "notify")
+		defer fake.Close()
+		fake.On("migrate", nil, testutil.FakeResponse{Stderr: "no db\n", ExitCode: 3})
+
+		cfg := newHooksConfig(t, map[string]interface{}{
+			"pre_pull":  []interface{}{hook("migrate"), hook("notify", "pre")},
+			"post_pull": []interface{}{hook("notify", "post")},
+		})
+
+		_, stderr, err := runTestCommand(cfg, []string{"pull"})
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "pre_pull hook failed (migrate): exit status 3", err.Error())
+			_, quiet := err.(*QuietError)
+			assert.True(t, quiet)
+		}
+		assert.Equal(t, "muss: running pre_pull hook: migrate\nno db\n", stderr)
+		assert.Len(t, fake.Calls("docker-compose"), 0, "command not run")
+		assert.Len(t, fake.Calls("notify"), 0, "later hooks not run")
+	})
+
+	t.Run("warn and ignore", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose", "migrate", "notify")
+		defer fake.Close()
+		fake.On("migrate", nil, testutil.FakeResponse{ExitCode: 1})
+		fake.On("notify", nil, testutil.FakeResponse{ExitCode: 1})
+
+		warn := hook("migrate")
+		warn["on_failure"] = "warn"
+		ignore := hook("notify")
+		ignore["on_failure"] = "ignore"
+		cfg := newHooksConfig(t, map[string]interface{}{
+			"pre_pull": []interface{}{warn, ignore},
+		})
+
+		_, stderr, err := runTestCommand(cfg, []string{"pull"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "muss: running pre_pull hook: migrate\n"+
+			"Warning: pre_pull hook failed (migrate): exit status 1\n"+
+			"muss: running pre_pull hook: notify\n", stderr)
+		assert.Len(t, fake.Calls("docker-compose"), 1)
+	})
+
+	t.Run("service hook", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		inService := hook("rake", "db:migrate")
+		inService["service"] = "app"
+		cfg := newHooksConfig(t, map[string]interface{}{
+			"post_pull": []interface{}{inService},
+		})
+
+		_, stderr, err := runTestCommand(cfg, []string{"pull"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "muss: running post_pull hook: rake db:migrate (in app)\n", stderr)
+		assert.Equal(t,
+			[][]string{{"pull"}, {"exec", "-T", "app", "rake", "db:migrate"}},
+			fake.Args("docker-compose"))
+	})
+
+	t.Run("no post hook after failure", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose", "notify")
+		defer fake.Close()
+		fake.On("docker-compose", []string{"pull"}, testutil.FakeResponse{ExitCode: 1})
+
+		cfg := newHooksConfig(t, map[string]interface{}{
+			"post_pull": []interface{}{hook("notify")},
+		})
+
+		_, _, err := runTestCommand(cfg, []string{"pull"})
+
+		assert.NotNil(t, err)
+		assert.Len(t, fake.Calls("notify"), 0)
+	})
+}
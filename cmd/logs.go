@@ -7,6 +7,7 @@ import (
 )
 
 func newLogsCommand(cfg *config.ProjectConfig) *cobra.Command {
+	unmask := false
 	var cmd = &cobra.Command{
 		Use:   "logs",
 		Short: "View output from services",
@@ -15,13 +16,27 @@ func newLogsCommand(cfg *config.ProjectConfig) *cobra.Command {
 		// TODO: ArgsInUseLine: "[service...]"
 		PreRunE: configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return DelegateCmd(
-				cmd,
-				dockerComposeCmd(cmd, args),
-			)
+			args, err := expandServices(cfg, args)
+			if err != nil {
+				return err
+			}
+			delegator := cmdDelegator(cmd)
+			if !unmask {
+				if err := redactOutput(delegator); err != nil {
+					return err
+				}
+			}
+			dcCmd, err := dockerComposeCmd(cmd, args)
+			if err != nil {
+				return err
+			}
+			return delegator.Delegate(dcCmd)
 		},
 	}
 
+	cmd.Flags().BoolVarP(&unmask, "unmask", "", false, "Do not mask secret values in the output.")
+	cmd.Flags().SetAnnotation("unmask", "muss-only", []string{"true"})
+
 	cmd.Flags().BoolP("no-color", "", false, "Produce monochrome output.")
 	cmd.Flags().BoolP("follow", "f", false, "Follow log output.")
 	cmd.Flags().BoolP("timestamps", "t", false, "Show timestamps.")
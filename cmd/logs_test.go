@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,6 +57,24 @@ web
 			assert.Equal(t, expOut, stdout)
 		})
 
+		t.Run("masks secrets", func(t *testing.T) {
+			os.Unsetenv("MUSS_TEST_REDACT")
+			defer os.Unsetenv("MUSS_TEST_REDACT")
+
+			cfg := newSecretTestConfig(t, "MUSS_TEST_REDACT", "hunter22")
+
+			stdout, stderr, err := runTestCommand(cfg, []string{"logs", "hunter22"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "std err\n", stderr)
+			assert.Equal(t, "docker-compose\nlogs\n********\n", stdout)
+
+			stdout, _, err = runTestCommand(cfg, []string{"logs", "--unmask", "hunter22"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "docker-compose\nlogs\nhunter22\n", stdout, "--unmask")
+		})
+
 		t.Run("no args", func(t *testing.T) {
 			stdout, stderr, err := runTestCommand(nil, []string{"logs"})
 
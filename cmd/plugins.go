@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gerrit.instructure.com/muss/backend"
+	"gerrit.instructure.com/muss/config"
+)
+
+// pluginPrefix is the prefix of executables on PATH that provide
+// subcommands ("muss-foo" runs as "muss foo").
+const pluginPrefix = "muss-"
+
+// Env vars set for plugins (along with backend.EnvVar).
+const (
+	pluginComposeFileVar   = "MUSS_COMPOSE_FILE"
+	pluginProjectFileVar   = "MUSS_PROJECT_FILE"
+	pluginProjectConfigVar = "MUSS_PROJECT_CONFIG"
+)
+
+// findPlugins returns the paths of plugins on PATH by name
+// (the first one found for a name wins, like the shell).
+func findPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			name := strings.TrimPrefix(file.Name(), pluginPrefix)
+			if name == file.Name() || name == "" || plugins[name] != "" {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+			// Follow symlinks to check what they point to.
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+				continue
+			}
+			plugins[name] = path
+		}
+	}
+	return plugins
+}
+
+// addPlugins adds commands for plugins on PATH (except those that have the
+// name of another command).
+// Listing them (for "help" and "completion") means checking every file on PATH
+// so otherwise it only looks for the plugin that args names
+// (if it isn't another command).
+func addPlugins(cfg *config.ProjectConfig, root *cobra.Command, args []string) {
+	name := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			name = arg
+			break
+		}
+	}
+
+	switch name {
+	case "", "help", "completion":
+		plugins := findPlugins()
+		names := make([]string, 0, len(plugins))
+		for name := range plugins {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !isCommand(root, name) {
+				root.AddCommand(newPluginCommand(cfg, name, plugins[name]))
+			}
+		}
+	default:
+		if isCommand(root, name) {
+			return
+		}
+		if path, err := exec.LookPath(pluginPrefix + name); err == nil {
+			root.AddCommand(newPluginCommand(cfg, name, path))
+		}
+	}
+}
+
+// isCommand returns true if name is a subcommand of root.
+func isCommand(root *cobra.Command, name string) bool {
+	sub, _, err := root.Find([]string{name})
+	return err == nil && sub != root
+}
+
+func newPluginCommand(cfg *config.ProjectConfig, name, path string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: fmt.Sprintf("Run the %s%s plugin", pluginPrefix, name),
+		Long: fmt.Sprintf(`Run the %s plugin (%s).
+
+The plugin gets the environment (including secrets) loaded by muss and:
+  %s: the path of the generated compose file
+  %s: the path of the project config file
+  %s: the project config as JSON
+  %s: the compose backend
+`, pluginPrefix+name, path, pluginComposeFileVar, pluginProjectFileVar, pluginProjectConfigVar, backend.EnvVar),
+		Args: cobra.ArbitraryArgs,
+		// Pass any flags to the plugin.
+		DisableFlagParsing: true,
+		PreRunE:            configSavePreRun(cfg),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := pluginEnv(cfg)
+			if err != nil {
+				return err
+			}
+			plugin := exec.Command(path, args...)
+			plugin.Env = append(os.Environ(), env...)
+			return DelegateCmd(cmd, plugin)
+		},
+	}
+
+	return cmd
+}
+
+// pluginEnv returns the env vars that describe the project to plugins.
+func pluginEnv(cfg *config.ProjectConfig) ([]string, error) {
+	if cfg == nil {
+		cfg, _ = config.NewConfigFromMap(nil)
+	}
+	cfgJSON, err := cfg.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("error converting project config to json: %w", err)
+	}
+
+	composeFile, err := filepath.Abs(cfg.ComposeFilePath())
+	if err != nil {
+		return nil, err
+	}
+	env := []string{
+		pluginComposeFileVar + "=" + composeFile,
+		pluginProjectConfigVar + "=" + string(cfgJSON),
+		backend.EnvVar + "=" + composeBackend().Name(),
+	}
+	if cfg.ProjectFile != "" {
+		if projectFile, err := filepath.Abs(cfg.ProjectFile); err == nil {
+			env = append(env, pluginProjectFileVar+"="+projectFile)
+		}
+	}
+	return env, nil
+}
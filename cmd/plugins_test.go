@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestFindPlugins(t *testing.T) {
+	first := testutil.NewFakeBin(t, "muss-hello", "muss-first")
+	defer first.Close()
+	second := testutil.NewFakeBin(t, "muss-hello", "muss-second")
+	defer second.Close()
+
+	// Not executable.
+	testutil.WriteFile(t, filepath.Join(second.Dir, "muss-data"), "")
+	// Not a plugin.
+	testutil.WriteFile(t, filepath.Join(second.Dir, "muss-"), "")
+	os.Chmod(filepath.Join(second.Dir, "muss-"), 0700)
+
+	plugins := findPlugins()
+
+	// Later fake bins are earlier in PATH.
+	assert.Equal(t, filepath.Join(second.Dir, "muss-hello"), plugins["hello"])
+	assert.Equal(t, filepath.Join(first.Dir, "muss-first"), plugins["first"])
+	assert.Equal(t, filepath.Join(second.Dir, "muss-second"), plugins["second"])
+	assert.NotContains(t, plugins, "data")
+	assert.NotContains(t, plugins, "")
+}
+
+func TestPlugins(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "muss-hello", "muss-up")
+		defer fake.Close()
+
+		stdout, _, err := runTestCommand(nil, []string{"help"})
+
+		assert.Nil(t, err)
+		assert.Contains(t, stdout, "hello       Run the muss-hello plugin\n")
+
+		root := newRootCommand(nil, []string{"help"})
+		up, _, _ := root.Find([]string{"up"})
+		assert.Equal(t, "Create and start containers", up.Short, "muss commands win")
+	})
+
+	t.Run("only what args need", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "muss-hello", "muss-up")
+		defer fake.Close()
+
+		for _, args := range [][]string{{"up"}, {"config", "show"}} {
+			root := newRootCommand(nil, args)
+			_, _, err := root.Find([]string{"hello"})
+			assert.NotNil(t, err, "not added for %v", args)
+		}
+
+		root := newRootCommand(nil, []string{"--non-interactive", "hello", "up"})
+		hello, _, err := root.Find([]string{"hello"})
+		if assert.Nil(t, err) {
+			assert.Equal(t, "Run the muss-hello plugin", hello.Short)
+		}
+
+		root = newRootCommand(nil, []string{"up"})
+		up, _, _ := root.Find([]string{"up"})
+		assert.Equal(t, "Create and start containers", up.Short, "muss commands win")
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "muss-hello")
+		defer fake.Close()
+
+		stdout, _, err := runTestCommand(nil, []string{"completion"})
+
+		assert.Nil(t, err)
+		assert.Contains(t, stdout, `commands+=("hello")`)
+	})
+
+	t.Run("run", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "muss-hello")
+		defer fake.Close()
+		fake.On("muss-hello", nil, testutil.FakeResponse{Stdout: "hi\n", ExitCode: 2})
+
+		testutil.WithTempDir(t, func(dir string) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"project_name": "proj",
+				"compose_file": "dc.muss.yml",
+				"backend":      "docker-compose",
+			})
+
+			stdout, _, err := runTestCommand(cfg, []string{"hello", "--name", "world"})
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "exit status 2", err.Error())
+			}
+			assert.Equal(t, "hi\n", stdout)
+
+			calls := fake.Calls("muss-hello")
+			if !assert.Len(t, calls, 1) {
+				return
+			}
+			assert.Equal(t, []string{"--name", "world"}, calls[0].Args)
+
+			wd, _ := os.Getwd()
+			env := calls[0].Env
+			assert.Equal(t, filepath.Join(wd, "dc.muss.yml"), env["MUSS_COMPOSE_FILE"])
+			assert.Equal(t, "docker-compose", env["MUSS_BACKEND"])
+
+			var project map[string]interface{}
+			if assert.Nil(t, json.Unmarshal([]byte(env["MUSS_PROJECT_CONFIG"]), &project)) {
+				assert.Equal(t, "proj", project["project_name"])
+			}
+		})
+	})
+}
@@ -19,14 +19,21 @@ func newPullCommand(cfg *config.ProjectConfig) *cobra.Command {
 
 			// TODO: pull repos
 
+			args, err := expandServices(cfg, args)
+			if err != nil {
+				return err
+			}
+
 			delegator := cmdDelegator(cmd)
-			err := delegator.FilterStderr(newDCErrorFilter(cfg))
+			err = delegator.FilterStderr(newDCErrorFilter(cfg))
+			if err != nil {
+				return err
+			}
+			dcCmd, err := dockerComposeCmd(cmd, args)
 			if err != nil {
 				return err
 			}
-			return delegator.Delegate(
-				dockerComposeCmd(cmd, args),
-			)
+			return delegator.Delegate(dcCmd)
 		},
 	}
 
@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
 )
 
 func TestPullCommand(t *testing.T) {
@@ -33,6 +36,25 @@ svc
 			assert.Equal(t, expOut, stdout)
 		})
 
+		t.Run("unsupported flags", func(t *testing.T) {
+			dir := testutil.Tempdir(t)
+			defer os.RemoveAll(dir)
+			testutil.WriteFile(t, path.Join(dir, "docker-compose"), "#!/bin/sh\n[ \"$1\" = version ] && echo 1.20.0 || echo \"$@\"\n")
+			os.Chmod(path.Join(dir, "docker-compose"), 0700)
+
+			orig := os.Getenv("PATH")
+			os.Setenv("PATH", dir+string(os.PathListSeparator)+orig)
+			defer os.Setenv("PATH", orig)
+
+			_, _, err := runTestCommand(nil, []string{"pull", "--include-deps", "svc"})
+			assert.Equal(t, "docker-compose 1.20.0 does not support pull --include-deps (requires 1.21.0)", err.Error())
+
+			stdout, stderr, err := runTestCommand(nil, []string{"up", "--detach", "--quiet-pull", "svc"})
+			assert.Nil(t, err)
+			assert.Equal(t, "Warning: docker-compose 1.20.0 does not support up --quiet-pull (ignoring it)\n", stderr)
+			assert.Equal(t, "up --detach svc\n", stdout)
+		})
+
 		t.Run("pull with private registry 403 without match", func(t *testing.T) {
 			os.Setenv("MUSS_TEST_REGISTRY_ERROR", "403")
 			defer os.Unsetenv("MUSS_TEST_REGISTRY_ERROR")
@@ -15,10 +15,15 @@ func newRestartCommand(cfg *config.ProjectConfig) *cobra.Command {
 		// TODO: ArgsInUseLine: "[service...]"
 		PreRunE: configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return DelegateCmd(
-				cmd,
-				dockerComposeCmd(cmd, args),
-			)
+			args, err := expandServices(cfg, args)
+			if err != nil {
+				return err
+			}
+			dcCmd, err := dockerComposeCmd(cmd, args)
+			if err != nil {
+				return err
+			}
+			return DelegateCmd(cmd, dcCmd)
 		},
 	}
 
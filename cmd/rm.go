@@ -20,10 +20,15 @@ Any data which is not in a volume will be lost.`,
 		// TODO: ArgsInUseLine: "[service...]"
 		PreRunE: configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return DelegateCmd(
-				cmd,
-				dockerComposeCmd(cmd, args),
-			)
+			args, err := expandServices(cfg, args)
+			if err != nil {
+				return err
+			}
+			dcCmd, err := dockerComposeCmd(cmd, args)
+			if err != nil {
+				return err
+			}
+			return DelegateCmd(cmd, dcCmd)
 		},
 	}
 
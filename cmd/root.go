@@ -2,11 +2,11 @@ package cmd
 
 import (
 	"fmt"
-	"os/exec"
 
 	"github.com/spf13/cobra"
 
 	config "gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/proc"
 )
 
 // CommandBuilder is a function that takes the project config as an argument
@@ -21,8 +21,17 @@ func AddCommandBuilder(f CommandBuilder) {
 	cmdBuilders = append(cmdBuilders, f)
 }
 
-// NewRootCommand takes a config value and returns a new root command.
+// NewRootCommand takes a config value and returns a new root command
+// (without any plugins since they depend on the args; see Execute).
 func NewRootCommand(cfg *config.ProjectConfig) *cobra.Command {
+	return newRootCommand(cfg, nil)
+}
+
+// newRootCommand returns a new root command
+// with the plugins needed for args (if not nil).
+func newRootCommand(cfg *config.ProjectConfig, args []string) *cobra.Command {
+	setBackend(cfg)
+
 	cmd := &cobra.Command{
 		Use:   "muss",
 		Short: "Configure and run project services",
@@ -31,9 +40,18 @@ func NewRootCommand(cfg *config.ProjectConfig) *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 	}
+	cmd.PersistentFlags().Bool("non-interactive", false,
+		"Do not prompt for input: secret and env commands get no stdin\nand fail rather than waiting (also MUSS_NONINTERACTIVE=1).")
+	cmd.PersistentFlags().SetAnnotation("non-interactive", "muss-only", []string{"true"})
+
 	for _, f := range cmdBuilders {
 		cmd.AddCommand(f(cfg))
 	}
+	addProjectCommands(cfg, cmd)
+	if args != nil {
+		addPlugins(cfg, cmd, args)
+	}
+	addHooks(cfg, cmd)
 	return cmd
 }
 
@@ -41,17 +59,35 @@ func NewRootCommand(cfg *config.ProjectConfig) *cobra.Command {
 func Execute(args []string) int {
 	// We'll inspect the error later when we have command context.
 	cfg, _ := config.NewConfigFromDefaultFile()
-	cmd := NewRootCommand(cfg)
+	if args == nil {
+		args = []string{}
+	}
+	cmd := newRootCommand(cfg, args)
 	return ExecuteRoot(cmd, args)
 }
 
+// exitCoder is implemented by errors from delegated commands
+// (which will have already printed their own messages).
+type exitCoder interface {
+	ExitCode() int
+}
+
+// isQuiet returns true for errors that should not print usage information.
+func isQuiet(err error) bool {
+	switch err.(type) {
+	case *QuietError, *proc.FilterError:
+		return true
+	}
+	return false
+}
+
 // ExecuteRoot executes the passed root command with the provided args.
 // This simplifies testing.
 func ExecuteRoot(rootCmd *cobra.Command, args []string) int {
 	rootCmd.SetArgs(args)
 	if err := rootCmd.Execute(); err != nil {
 		// Propagate errors from command delegation.
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		if exitErr, ok := err.(exitCoder); ok {
 			return exitErr.ExitCode()
 		}
 
@@ -62,7 +98,7 @@ func ExecuteRoot(rootCmd *cobra.Command, args []string) int {
 
 		// An alternative to marking it a QuietError is to call
 		// rootCmd.SetFlagErrorFunc and wrap flag errors with a flagError type.
-		if _, ok := err.(*QuietError); !ok {
+		if !isQuiet(err) {
 			// Print usage if it's a flag error
 			cmd, _, findErr := rootCmd.Find(args)
 			// If subcmd isn't found, print root command usage
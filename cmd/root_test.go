@@ -79,6 +79,15 @@ func TestRootCommand(t *testing.T) {
 			assert.Equal(t, "", stderr)
 		})
 
+		t.Run("multiple delegated command failures", func(t *testing.T) {
+			exitCode, stdout, stderr := testRootCmd("wrap", "-c", "exit 3", "-c", "true")
+
+			assert.Equal(t, 3, exitCode, "exit code of the failed command")
+			assert.Equal(t, "", stdout)
+			assert.Contains(t, stderr, "1 of 2 commands failed:\n")
+			assert.NotContains(t, stderr, "Error:", "no error message or usage")
+		})
+
 		t.Run("success", func(t *testing.T) {
 			exitCode, stdout, stderr := testRootCmd("pull")
 
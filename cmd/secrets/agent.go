@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"gerrit.instructure.com/muss/agent"
+	rootcmd "gerrit.instructure.com/muss/cmd"
+	"gerrit.instructure.com/muss/config"
+)
+
+func newAgentCommand(cfg *config.ProjectConfig) *cobra.Command {
+	ttl := agent.DefaultTTL
+	var cmd = &cobra.Command{
+		Use:   "agent",
+		Short: "Run an agent to hold decrypted secrets in memory",
+		Long: `Run an agent (in the foreground) that holds decrypted secrets in memory
+so that other muss commands don't need to decrypt the cache each time.
+
+The agent listens on a unix socket in the user cache dir
+(or the path in ` + agent.SocketEnvVar + `).
+Values are kept until the secret's cache would expire (or the --ttl)
+or until "muss secrets lock" is run.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			socket := agent.SocketPath()
+
+			// Close the listener (which removes the socket) when stopped.
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+			defer signal.Stop(signals)
+
+			listener, err := agent.Listen(socket)
+			if err != nil {
+				return rootcmd.QuietErrorOrNil(err)
+			}
+
+			stopped := make(chan bool, 1)
+			go func() {
+				<-signals
+				stopped <- true
+				listener.Close()
+			}()
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "muss agent listening on %s\n", socket)
+
+			err = agent.New(ttl).Serve(listener)
+			select {
+			case <-stopped:
+				return nil
+			default:
+				return rootcmd.QuietErrorOrNil(err)
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&ttl, "ttl", ttl, "How long to keep secrets that don't have a cache duration")
+
+	return cmd
+}
+
+func newLockCommand(cfg *config.ProjectConfig) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Remove all secrets from the agent",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rootcmd.QuietErrorOrNil(agent.NewClient(agent.SocketPath()).Lock())
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	AddCommandBuilder(newAgentCommand)
+	AddCommandBuilder(newLockCommand)
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/agent"
+	rootcmd "gerrit.instructure.com/muss/cmd"
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func testSecretsCommand(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+
+	cfg, _ := config.NewConfigFromMap(nil)
+	return testSecretsCommandWithConfig(t, cfg, args...)
+}
+
+func testSecretsCommandWithConfig(t *testing.T, cfg *config.ProjectConfig, args ...string) (string, string, int) {
+	t.Helper()
+
+	var stdout, stderr strings.Builder
+
+	cmd := rootcmd.NewRootCommand(cfg)
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	exitCode := rootcmd.ExecuteRoot(cmd, append([]string{"secrets"}, args...))
+
+	return stdout.String(), stderr.String(), exitCode
+}
+
+func TestSecretsAgentCommand(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		socket := path.Join(dir, "agent.sock")
+		os.Setenv(agent.SocketEnvVar, socket)
+		defer os.Unsetenv(agent.SocketEnvVar)
+
+		t.Run("lock without agent", func(t *testing.T) {
+			_, stderr, ec := testSecretsCommand(t, "lock")
+
+			assert.Equal(t, 1, ec)
+			assert.Equal(t, "Error:  no agent running at "+socket+"\n", stderr)
+		})
+
+		type result struct {
+			stderr string
+			ec     int
+		}
+		done := make(chan result, 1)
+		go func() {
+			_, stderr, ec := testSecretsCommand(t, "agent")
+			done <- result{stderr, ec}
+		}()
+
+		client := agent.NewClient(socket)
+		for i := 0; client.Ping() != nil; i++ {
+			if i > 100 {
+				t.Fatal("agent did not start")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Run("lock", func(t *testing.T) {
+			assert.Nil(t, client.Set("k", []byte("v"), 0))
+
+			_, stderr, ec := testSecretsCommand(t, "lock")
+
+			assert.Equal(t, 0, ec)
+			assert.Equal(t, "", stderr)
+
+			_, found, err := client.Get("k")
+			assert.Nil(t, err)
+			assert.False(t, found, "locked")
+		})
+
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+		select {
+		case r := <-done:
+			assert.Equal(t, 0, r.ec)
+			assert.Equal(t, "muss agent listening on "+socket+"\n", r.stderr)
+		case <-time.After(5 * time.Second):
+			t.Fatal("agent did not stop")
+		}
+		testutil.NoFileExists(t, socket)
+	})
+}
@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	rootcmd "gerrit.instructure.com/muss/cmd"
+	"gerrit.instructure.com/muss/config"
+)
+
+func newAuditCommand(cfg *config.ProjectConfig) *cobra.Command {
+	opts := struct {
+		all     bool
+		name    string
+		command string
+		since   time.Duration
+		failed  bool
+		json    bool
+	}{}
+
+	var cmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Show the secrets audit log",
+		Long: `Show entries from the secrets audit log for the current project.
+
+Secrets are only logged when "audit_log: true" is set in the project config.
+Each entry records when a secret was loaded, whether it came from the cache,
+and whether it succeeded (never the value).`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := config.AuditFilter{
+				Name:    opts.name,
+				Command: opts.command,
+				Failed:  opts.failed,
+			}
+			if !opts.all {
+				filter.Project = config.AuditProject()
+			}
+			if opts.since > 0 {
+				filter.Since = time.Now().Add(-opts.since)
+			}
+
+			entries, err := config.ReadAuditLog(filter)
+			if err != nil {
+				return rootcmd.QuietErrorOrNil(err)
+			}
+
+			if opts.json {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				for _, e := range entries {
+					if err := encoder.Encode(e); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			if opts.all {
+				fmt.Fprint(w, "PROJECT\t")
+			}
+			fmt.Fprintln(w, "TIME\tNAME\tCOMMAND\tCACHE\tRESULT")
+			for _, e := range entries {
+				if opts.all {
+					fmt.Fprintf(w, "%s\t", e.Project)
+				}
+				result := "ok"
+				if !e.Success {
+					result = "failed: " + e.Error
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Name, e.Command, e.Cache, result)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Show entries for all projects")
+	cmd.Flags().StringVar(&opts.name, "name", "", "Only show entries for the secret with this env var `name`")
+	cmd.Flags().StringVar(&opts.command, "command", "", "Only show entries for this secret `command`")
+	cmd.Flags().DurationVar(&opts.since, "since", 0, "Only show entries from this `duration` ago (like 24h)")
+	cmd.Flags().BoolVar(&opts.failed, "failed", false, "Only show failures")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print entries as json lines")
+
+	return cmd
+}
+
+func init() {
+	AddCommandBuilder(newAuditCommand)
+}
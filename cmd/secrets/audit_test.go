@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestSecretsAuditCommand(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		stdout, stderr, ec := testSecretsCommand(t, "audit")
+		assert.Equal(t, 0, ec)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, "TIME  NAME  COMMAND  CACHE  RESULT\n", stdout, "no log")
+
+		project := config.AuditProject()
+		if err := os.MkdirAll(path.Dir(config.AuditLogFile()), 0700); err != nil {
+			t.Fatal(err)
+		}
+		testutil.WriteFile(t, config.AuditLogFile(),
+			`{"time":"2020-03-01T10:00:00Z","project":"`+project+`","name":"DB_PASS","command":"vault","cache":"miss","success":true}
+{"time":"2020-03-01T11:00:00Z","project":"/elsewhere","name":"API_KEY","command":"vault","cache":"hit","success":true}
+{"time":"2020-03-01T12:00:00Z","project":"`+project+`","name":"API_KEY","command":"vault","cache":"miss","success":false,"error":"failed to get secret: command failed: exit status 2"}
+`)
+
+		stdout, _, ec = testSecretsCommand(t, "audit", "--json")
+		assert.Equal(t, 0, ec)
+		assert.Equal(t,
+			`{"time":"2020-03-01T10:00:00Z","project":"`+project+`","name":"DB_PASS","command":"vault","cache":"miss","success":true}
+{"time":"2020-03-01T12:00:00Z","project":"`+project+`","name":"API_KEY","command":"vault","cache":"miss","success":false,"error":"failed to get secret: command failed: exit status 2"}
+`, stdout, "current project")
+
+		stdout, _, _ = testSecretsCommand(t, "audit", "--all", "--name", "API_KEY", "--json")
+		assert.Contains(t, stdout, `"project":"/elsewhere"`, "all projects")
+		assert.NotContains(t, stdout, "DB_PASS", "name")
+
+		stdout, _, _ = testSecretsCommand(t, "audit", "--failed")
+		assert.Regexp(t, `^TIME\s+NAME\s+COMMAND\s+CACHE\s+RESULT\n`+
+			`2020-03-01 \d\d:00:00\s+API_KEY\s+vault\s+miss\s+failed: failed to get secret: command failed: exit status 2\n$`, stdout)
+
+		stdout, _, _ = testSecretsCommand(t, "audit", "--since", "24h", "--json")
+		assert.Equal(t, "", stdout, "since")
+	})
+}
@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	rootcmd "gerrit.instructure.com/muss/cmd"
+	"gerrit.instructure.com/muss/config"
+)
+
+func newListCommand(cfg *config.ProjectConfig) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "list",
+		Short: "List secrets and their cache status",
+		Long: `List the configured secrets and the state of their cache.
+
+Cache states:
+  cached:   a value is cached
+  expired:  the cached value is past its cache duration
+  stale:    the command failed and the expired value was used
+  missing:  no value is cached
+  disabled: the secret is not cached`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statuses, err := cfg.SecretStatuses()
+			if err != nil {
+				return rootcmd.QuietErrorOrNil(err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tCOMMAND\tCACHE\tUPDATED\tNOTE")
+			for _, s := range statuses {
+				updated := "-"
+				if !s.Updated.IsZero() {
+					updated = s.Updated.Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s", s.Name, s.Command, s.Cache, updated)
+				if s.Error != "" {
+					fmt.Fprintf(w, "\t%s", s.Error)
+				}
+				fmt.Fprintln(w)
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	AddCommandBuilder(newListCommand)
+}
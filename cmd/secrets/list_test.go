@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestSecretsListCommand(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		cfg, err := config.NewConfigFromMap(map[string]interface{}{
+			"secret_passphrase": "$MUSS_TEST_PASSPHRASE",
+			"secret_commands": map[string]interface{}{
+				"vault": map[string]interface{}{
+					"exec":           []string{"echo"},
+					"cache":          "1h",
+					"stale_on_error": true,
+				},
+			},
+			"service_definitions": []map[string]interface{}{
+				map[string]interface{}{
+					"name": "app",
+					"configs": map[string]interface{}{
+						"sole": map[string]interface{}{
+							"secrets": map[string]interface{}{
+								"MUSS_TEST_DB": map[string]interface{}{
+									"vault": []string{"db"},
+								},
+							},
+							"services": map[string]interface{}{
+								"app": map[string]interface{}{"image": "alpine"},
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stdout, stderr, ec := testSecretsCommandWithConfig(t, cfg, "list")
+
+		assert.Equal(t, 0, ec)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, `NAME          COMMAND  CACHE    UPDATED  NOTE
+MUSS_TEST_DB  vault    missing  -
+`, stdout)
+	})
+}
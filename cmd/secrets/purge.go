@@ -0,0 +1,37 @@
+package secrets
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	rootcmd "gerrit.instructure.com/muss/cmd"
+	"gerrit.instructure.com/muss/config"
+)
+
+func newPurgeCommand(cfg *config.ProjectConfig) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "purge [NAME...]",
+		Short: "Remove cached secrets",
+		Long: `Remove the cached values of the named secrets (or all secrets)
+so that they will be fetched (or generated) again.
+
+Names are the env vars listed by "muss secrets list".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			purged, err := cfg.PurgeSecrets(args...)
+			for _, name := range purged {
+				fmt.Fprintf(cmd.OutOrStdout(), "Purged %s\n", name)
+			}
+			if err == nil && len(purged) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No cached secrets to purge.")
+			}
+			return rootcmd.QuietErrorOrNil(err)
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	AddCommandBuilder(newPurgeCommand)
+}
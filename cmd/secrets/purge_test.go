@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestSecretsPurgeCommand(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		os.Setenv("MUSS_TEST_PASSPHRASE", "purge")
+		defer os.Unsetenv("MUSS_TEST_PASSPHRASE")
+		os.Unsetenv("MUSS_TEST_GEN")
+		defer os.Unsetenv("MUSS_TEST_GEN")
+
+		cfg, err := config.NewConfigFromMap(map[string]interface{}{
+			"secret_passphrase": "$MUSS_TEST_PASSPHRASE",
+			"service_definitions": []map[string]interface{}{
+				map[string]interface{}{
+					"name": "app",
+					"configs": map[string]interface{}{
+						"sole": map[string]interface{}{
+							"secrets": map[string]interface{}{
+								"MUSS_TEST_GEN": map[string]interface{}{
+									"generate": map[string]interface{}{"format": "uuid"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cfg.ComposeConfig(); err != nil {
+			t.Fatal(err)
+		}
+
+		stdout, stderr, ec := testSecretsCommandWithConfig(t, cfg, "purge")
+		assert.Equal(t, 0, ec)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, "No cached secrets to purge.\n", stdout)
+
+		if err := cfg.LoadEnv(); err != nil {
+			t.Fatal(err)
+		}
+
+		stdout, stderr, ec = testSecretsCommandWithConfig(t, cfg, "purge", "MUSS_TEST_GEN")
+		assert.Equal(t, 0, ec)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, "Purged MUSS_TEST_GEN\n", stdout)
+
+		_, stderr, ec = testSecretsCommandWithConfig(t, cfg, "purge", "NOPE")
+		assert.Equal(t, 1, ec)
+		assert.Equal(t, "Error:  no secret named \"NOPE\"\n", stderr)
+	})
+}
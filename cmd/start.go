@@ -7,6 +7,7 @@ import (
 )
 
 func newStartCommand(cfg *config.ProjectConfig) *cobra.Command {
+	recreateChanged := false
 	var cmd = &cobra.Command{
 		Use:   "start",
 		Short: "Start services",
@@ -15,13 +16,24 @@ func newStartCommand(cfg *config.ProjectConfig) *cobra.Command {
 		// TODO: ArgsInUseLine: "[service...]"
 		PreRunE: configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return DelegateCmd(
-				cmd,
-				dockerComposeCmd(cmd, args),
-			)
+			args, err := expandServices(cfg, args)
+			if err != nil {
+				return err
+			}
+			if _, err := handleChangedSecrets(cmd, cfg, recreateChanged); err != nil {
+				return err
+			}
+			dcCmd, err := dockerComposeCmd(cmd, args)
+			if err != nil {
+				return err
+			}
+			return DelegateCmd(cmd, dcCmd)
 		},
 	}
 
+	cmd.Flags().BoolVarP(&recreateChanged, "recreate-changed", "", false, "Recreate running services that use secrets\nwhose values have changed.")
+	cmd.Flags().SetAnnotation("recreate-changed", "muss-only", []string{"true"})
+
 	return cmd
 }
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,5 +20,79 @@ start
 			assert.Equal(t, "std err\n", stderr)
 			assert.Equal(t, expOut, stdout)
 		})
+
+		t.Run("changed secrets", func(t *testing.T) {
+			orig := changedSecretVars
+			defer func() { changedSecretVars = orig }()
+			changedSecretVars = func() ([]string, error) {
+				return []string{"MUSS_TEST_KEY"}, nil
+			}
+			var recorded [][]string
+			origRecord := recordSecretVars
+			defer func() { recordSecretVars = origRecord }()
+			recordSecretVars = func(skip []string) error {
+				recorded = append(recorded, skip)
+				return nil
+			}
+
+			os.Setenv("MUSS_TEST_RUNNING_SERVICES", "app db")
+			defer os.Unsetenv("MUSS_TEST_RUNNING_SERVICES")
+
+			cfg := newTestConfig(t, map[string]interface{}{
+				"service_definitions": []map[string]interface{}{
+					map[string]interface{}{
+						"name": "app",
+						"configs": map[string]interface{}{
+							"sole": map[string]interface{}{
+								"services": map[string]interface{}{
+									"app": map[string]interface{}{
+										"image":       "alpine",
+										"environment": map[string]interface{}{"MUSS_TEST_KEY": nil},
+									},
+									"worker": map[string]interface{}{
+										"image":   "alpine",
+										"command": "work ${MUSS_TEST_KEY}",
+									},
+									"db": map[string]interface{}{
+										"image": "postgres",
+									},
+								},
+							},
+						},
+					},
+				},
+			})
+
+			stdout, stderr, err := runTestCommand(cfg, []string{"start"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "Secrets have changed (MUSS_TEST_KEY) for running services: app\nUse --recreate-changed to recreate them with the new values.\nstd err\n", stderr)
+			assert.Equal(t, "docker-compose\nstart\n", stdout)
+			assert.Equal(t, [][]string{{"MUSS_TEST_KEY"}}, recorded, "changed values not recorded")
+
+			recorded = nil
+			stdout, stderr, err = runTestCommand(cfg, []string{"start", "--recreate-changed"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "Recreating services with changed secrets: app\nstd err\nstd err\n", stderr)
+			assert.Equal(t, "docker-compose\nup\n--detach\n--no-deps\n--force-recreate\napp\ndocker-compose\nstart\n", stdout)
+			assert.Equal(t, [][]string{{"MUSS_TEST_KEY"}, nil}, recorded, "recorded after recreating")
+
+			recorded = nil
+			os.Setenv("MUSS_TEST_UP_ERROR", "1")
+			_, _, err = runTestCommand(cfg, []string{"start", "--recreate-changed"})
+			os.Unsetenv("MUSS_TEST_UP_ERROR")
+
+			assert.NotNil(t, err)
+			assert.Equal(t, [][]string{{"MUSS_TEST_KEY"}}, recorded, "not recorded when recreating fails")
+
+			recorded = nil
+			os.Setenv("MUSS_TEST_RUNNING_SERVICES", "db")
+			stdout, stderr, err = runTestCommand(cfg, []string{"start"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "std err\n", stderr)
+			assert.Equal(t, [][]string{nil}, recorded, "recorded when nothing uses the old values")
+		})
 	})
 }
@@ -28,6 +28,19 @@ svc
 			assert.Equal(t, expOut, stdout)
 		})
 
+		t.Run("selectors", func(t *testing.T) {
+			stdout, _, err := runTestCommand(newSelectorsConfig(t), []string{"stop", "@backend", "!app"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "docker-compose\nstop\nworker\n", stdout)
+
+			_, _, err = runTestCommand(newSelectorsConfig(t), []string{"stop", "def:nope"})
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "service definition 'nope' not found", err.Error())
+			}
+		})
+
 		t.Run("no args", func(t *testing.T) {
 			stdout, stderr, err := runTestCommand(nil, []string{"stop"})
 
@@ -13,13 +13,18 @@ import (
 	"github.com/spf13/cobra"
 
 	"gerrit.instructure.com/muss/config"
+	"gerrit.instructure.com/muss/engine"
 	"gerrit.instructure.com/muss/proc"
 	"gerrit.instructure.com/muss/term"
 )
 
 func newUpCommand(cfg *config.ProjectConfig) *cobra.Command {
 	opts := struct {
-		noStatus bool
+		noStatus        bool
+		unmask          bool
+		recreateChanged bool
+		wait            bool
+		waitTimeout     time.Duration
 
 		detach               bool
 		noColor              bool
@@ -62,9 +67,36 @@ If you want to force Compose to stop and recreate all containers, use the
 		PreRunE: configSavePreRun(cfg),
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
 
+			if args, err = expandServices(cfg, args); err != nil {
+				return err
+			}
+
+			stale, err := handleChangedSecrets(cmd, cfg, opts.recreateChanged)
+			if err != nil {
+				return err
+			}
+
+			if opts.wait {
+				if opts.noStart || opts.abortOnContainerExit || opts.exitCodeFrom != "" {
+					return fmt.Errorf("--wait is incompatible with --no-start, --abort-on-container-exit and --exit-code-from")
+				}
+				// Compose can't report the health of the services
+				// so check that we can ask the engine before starting anything.
+				if _, err := engineClient().Containers(engine.Filter{Project: composeProject(cfg)}); err != nil {
+					return fmt.Errorf("--wait requires access to the container engine API: %w", err)
+				}
+				// Start in the background so that we can check on them.
+				cmd.Flags().Set("detach", "true")
+			}
+
 			stopAfter := true
 
 			delegator := cmdDelegator(cmd)
+			if !opts.unmask {
+				if err = redactOutput(delegator); err != nil {
+					return err
+				}
+			}
 			err = delegator.FilterStderr(newDCErrorFilter(cfg))
 			if err != nil {
 				return err
@@ -82,11 +114,23 @@ If you want to force Compose to stop and recreate all containers, use the
 				if err != nil {
 					return err
 				}
+				// Keep the colors of the logs (when writing to a terminal).
+				delegator.UsePTY = true
 			}
 
-			err = delegator.Delegate(
-				dockerComposeCmd(cmd, args),
-			)
+			dcCmd, err := dockerComposeCmd(cmd, args)
+			if err != nil {
+				return err
+			}
+			err = delegator.Delegate(dcCmd)
+
+			// Compose recreates services whose config (including the secret
+			// values) has changed so we can record the new values now.
+			if err == nil && len(stale) > 0 && !opts.noRecreate && !opts.noStart && includesServices(args, stale) {
+				if rerr := recordSecretVars(nil); rerr != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: unable to record secret values: %s\n", rerr)
+				}
+			}
 
 			// When you interrupt "up" it will usually stop all the services
 			// but sometimes dc just aborts.  If we call stop afterwards it will
@@ -95,10 +139,15 @@ If you want to force Compose to stop and recreate all containers, use the
 				err = DelegateCmd(
 					cmd,
 					// Pass args so that we only stop services that this command started.
-					exec.Command("docker-compose", append([]string{"stop"}, args...)...),
+					composeCmd(append([]string{"stop"}, args...)...),
 				)
 			}
 
+			if err == nil && opts.wait {
+				showStatus := !opts.noStatus && isTerminal(cmd.OutOrStdout())
+				err = waitForServices(cmd.OutOrStdout(), cmd.ErrOrStderr(), engineClient(), composeProject(cfg), args, opts.waitTimeout, showStatus)
+			}
+
 			return
 		},
 	}
@@ -107,6 +156,15 @@ If you want to force Compose to stop and recreate all containers, use the
 	// muss only
 	cmd.Flags().BoolVarP(&opts.noStatus, "no-status", "", false, "Do not show muss status at the bottom of the log output.")
 	cmd.Flags().SetAnnotation("no-status", "muss-only", []string{"true"})
+	cmd.Flags().BoolVarP(&opts.unmask, "unmask", "", false, "Do not mask secret values in the output.")
+	cmd.Flags().SetAnnotation("unmask", "muss-only", []string{"true"})
+	cmd.Flags().BoolVarP(&opts.recreateChanged, "recreate-changed", "", false, "Recreate running services that use secrets\nwhose values have changed.")
+	cmd.Flags().SetAnnotation("recreate-changed", "muss-only", []string{"true"})
+
+	cmd.Flags().BoolVarP(&opts.wait, "wait", "", false, "Start in the background and wait for the services to be\nhealthy (or running if they have no healthcheck).")
+	cmd.Flags().SetAnnotation("wait", "muss-only", []string{"true"})
+	cmd.Flags().DurationVarP(&opts.waitTimeout, "wait-timeout", "", 0, "Stop waiting after this `duration` (default: no limit).")
+	cmd.Flags().SetAnnotation("wait-timeout", "muss-only", []string{"true"})
 
 	cmd.Flags().BoolVarP(&opts.detach, "detach", "d", false, "Detached mode: Run containers in the background,\nprint new container names. Incompatible with\n--abort-on-container-exit.")
 	cmd.Flags().BoolVarP(&opts.noColor, "no-color", "", false, "Produce monochrome output.")
@@ -132,42 +190,36 @@ func init() {
 	AddCommandBuilder(newUpCommand)
 }
 
+// upStatusFilter writes the output along with a status line
+// that stays at the bottom of the screen.
 type upStatusFilter struct {
-	*proc.Pipe
-	cfg          *config.ProjectConfig
-	readerDoneCh chan bool
+	*proc.LineFilter
+	cfg      *config.ProjectConfig
+	outputCh chan []byte
 }
 
 func newUpStatusFilter(cfg *config.ProjectConfig) proc.StreamFilter {
-	return &upStatusFilter{
-		cfg:          cfg,
-		readerDoneCh: make(chan bool, 1),
-		Pipe:         &proc.Pipe{},
+	f := &upStatusFilter{
+		cfg: cfg,
+		// Setup a channel for log output.
+		outputCh: make(chan []byte, 10),
 	}
+	f.LineFilter = proc.NewLineFilter(f.queueLine)
+	f.Split = bufio.ScanLines
+	f.OnStart = f.start
+	return f
 }
 
-func (f *upStatusFilter) Stop() {
-	// Wait for go routines to finish.
-	<-f.readerDoneCh
+// queueLine sends the line to be written with the status line
+// (rather than returning it to be written directly).
+func (f *upStatusFilter) queueLine(line []byte) ([]byte, error) {
+	// Copy the line since the scanner will reuse its buffer.
+	f.outputCh <- append([]byte(nil), line...)
+	return nil, nil
 }
 
-func (f *upStatusFilter) Start(done chan bool) {
+func (f *upStatusFilter) start(done chan bool, writer io.Writer) {
 	cfg := f.cfg
-	reader := f.Reader()
-	writer := f.Writer()
-
-	// Setup a channel for log output.
-	outputCh := make(chan []byte, 10)
-	go func() {
-		if f, ok := reader.(io.ReadCloser); ok {
-			defer f.Close()
-		}
-		scanner := bufio.NewScanner(reader)
-		for scanner.Scan() {
-			outputCh <- scanner.Bytes()
-		}
-		f.readerDoneCh <- true
-	}()
 
 	// Setup a channel for status updates.
 	statusCh := make(chan string, 1)
@@ -205,5 +257,23 @@ func (f *upStatusFilter) Start(done chan bool) {
 		}()
 	}
 
-	go term.WriteWithFixedStatusLine(writer, outputCh, statusCh, done)
+	go term.WriteWithFixedStatusLine(writer, f.outputCh, statusCh, done)
+}
+
+// includesServices returns true if "up" with the args (all services if empty)
+// starts all of the services.
+func includesServices(args, services []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	included := make(map[string]bool, len(args))
+	for _, arg := range args {
+		included[arg] = true
+	}
+	for _, svc := range services {
+		if !included[svc] {
+			return false
+		}
+	}
+	return true
 }
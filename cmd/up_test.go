@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"gerrit.instructure.com/muss/term"
+	"gerrit.instructure.com/muss/testutil"
 )
 
 func TestUpCommand(t *testing.T) {
@@ -126,6 +130,59 @@ piyo
 			assert.Equal(t, expOut, stdout)
 		})
 
+		t.Run("up with changed secrets", func(t *testing.T) {
+			origChanged, origRecord := changedSecretVars, recordSecretVars
+			defer func() { changedSecretVars, recordSecretVars = origChanged, origRecord }()
+			changedSecretVars = func() ([]string, error) {
+				return []string{"MUSS_TEST_KEY"}, nil
+			}
+			var recorded [][]string
+			recordSecretVars = func(skip []string) error {
+				recorded = append(recorded, skip)
+				return nil
+			}
+
+			os.Setenv("MUSS_TEST_RUNNING_SERVICES", "app")
+			defer os.Unsetenv("MUSS_TEST_RUNNING_SERVICES")
+
+			cfg := newTestConfig(t, map[string]interface{}{
+				"service_definitions": []map[string]interface{}{
+					map[string]interface{}{
+						"name": "app",
+						"configs": map[string]interface{}{
+							"sole": map[string]interface{}{
+								"services": map[string]interface{}{
+									"app": map[string]interface{}{
+										"image":       "alpine",
+										"environment": map[string]interface{}{"MUSS_TEST_KEY": nil},
+									},
+									"db": map[string]interface{}{
+										"image": "postgres",
+									},
+								},
+							},
+						},
+					},
+				},
+			})
+
+			for _, args := range [][]string{{"db"}, {"--no-recreate"}} {
+				recorded = nil
+				_, _, err := runTestCommand(cfg, append([]string{"up", "-d"}, args...))
+
+				assert.Nil(t, err)
+				assert.Equal(t, [][]string{{"MUSS_TEST_KEY"}}, recorded, "app not recreated with %v", args)
+			}
+
+			for _, args := range [][]string{{}, {"app"}} {
+				recorded = nil
+				_, _, err := runTestCommand(cfg, append([]string{"up", "-d"}, args...))
+
+				assert.Nil(t, err)
+				assert.Equal(t, [][]string{{"MUSS_TEST_KEY"}, nil}, recorded, "app recreated with %v", args)
+			}
+		})
+
 		t.Run("up without muss.yaml", func(t *testing.T) {
 			os.Setenv("MUSS_TEST_UP_LOGS", "1")
 			defer os.Unsetenv("MUSS_TEST_UP_LOGS")
@@ -285,3 +342,211 @@ piyo
 
 	})
 }
+
+func TestUpWithFakeCompose(t *testing.T) {
+	t.Run("stop after up", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+		fake.On("docker-compose", []string{"up"}, testutil.FakeResponse{
+			Output: []testutil.FakeOutput{{Stdout: "log 1\n"}, {Delay: 100 * time.Millisecond, Stdout: "log 2\n"}},
+		})
+
+		os.Setenv("MUSS_TEST_FAKE_ENV", "passed")
+		defer os.Unsetenv("MUSS_TEST_FAKE_ENV")
+
+		stdout, stderr, err := runTestCommand(nil, []string{"up", "--no-status", "hoge"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, "log 1\nlog 2\n", stdout)
+
+		calls := fake.Calls("docker-compose")
+		assert.Equal(t, [][]string{{"up", "hoge"}, {"stop", "hoge"}}, fake.Args("docker-compose"))
+		assert.Equal(t, "passed", calls[0].Env["MUSS_TEST_FAKE_ENV"])
+	})
+
+	t.Run("selectors", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		_, _, err := runTestCommand(newSelectorsConfig(t), []string{"up", "--no-status", "@backend"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{{"up", "app", "worker"}, {"stop", "app", "worker"}}, fake.Args("docker-compose"))
+	})
+
+	t.Run("registry hint", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+		fake.On("docker-compose", []string{"up"}, testutil.FakeResponse{
+			Stderr:   "Get https://private.registry/v2/ns/image/manifests/tag: no basic auth credentials\n",
+			ExitCode: 1,
+		})
+
+		_, stderr, err := runTestCommand(nil, []string{"up", "--no-status"})
+
+		assert.Equal(t, "exit status 1", err.Error())
+		assert.Equal(t, "Get https://private.registry/v2/ns/image/manifests/tag: no basic auth credentials\n\nYou may need to login to private.registry\n", stderr)
+		assert.Equal(t, [][]string{{"up"}}, fake.Args("docker-compose"), "not stopped after failure")
+	})
+
+	t.Run("terminated", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+		fake.On("docker-compose", []string{"up"}, testutil.FakeResponse{
+			Stdout:        "started\n",
+			WaitForSignal: true,
+		})
+
+		// Make sure we don't exit if the signal arrives before muss is listening.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+
+		go func() {
+			for i := 0; i < 100 && len(fake.Calls("docker-compose")) == 0; i++ {
+				time.Sleep(50 * time.Millisecond)
+			}
+			syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		}()
+
+		stdout, _, err := runTestCommand(nil, []string{"up", "--no-status"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "started\n", stdout)
+
+		calls := fake.Calls("docker-compose")
+		if assert.Equal(t, 2, len(calls)) {
+			assert.Equal(t, []string{"terminated"}, calls[0].Signals, "signal forwarded")
+			assert.Equal(t, []string{"stop"}, calls[1].Args, "stopped after")
+		}
+	})
+}
+
+func TestUpWait(t *testing.T) {
+	origInterval := waitPollInterval
+	waitPollInterval = 10 * time.Millisecond
+	defer func() { waitPollInterval = origInterval }()
+
+	cfg := newTestConfig(t, map[string]interface{}{"project_name": "proj"})
+
+	t.Run("ready", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "web1", Project: "proj", Service: "web", Number: 1, Health: "healthy", Starting: 2},
+			{ID: "db1", Project: "proj", Service: "db", Number: 1},
+			{ID: "other1", Project: "other", Service: "web", Number: 1, State: "exited"},
+		}, func(t *testing.T) {
+			stdout, stderr, err := runTestCommand(cfg, []string{"up", "--wait"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "", stderr)
+			assert.Equal(t, "", stdout, "no status line when not a terminal")
+			assert.Equal(t, [][]string{{"up", "--detach"}}, fake.Args("docker-compose"), "not stopped")
+		})
+	})
+
+	t.Run("status line", func(t *testing.T) {
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "web1", Project: "proj", Service: "web", Number: 1, Health: "healthy", Starting: 2},
+			{ID: "db1", Project: "proj", Service: "db", Number: 1},
+		}, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			err := waitForServices(&stdout, &stderr, engineClient(), "proj", nil, 0, true)
+
+			assert.Nil(t, err)
+			assert.Equal(t, "", stderr.String())
+			assert.Contains(t, stdout.String(), "# muss: 1 of 2 services ready; waiting for web (starting)")
+			assert.True(t, strings.HasSuffix(stdout.String(), term.AnsiEraseToEnd+term.AnsiReset+term.AnsiReset+term.AnsiStart), "status cleared")
+		})
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "web1", Project: "proj", Service: "web", Number: 1},
+			{ID: "migrate1", Project: "proj", Service: "migrate", Number: 1, State: "exited"},
+		}, func(t *testing.T) {
+			_, stderr, err := runTestCommand(cfg, []string{"up", "--wait"})
+
+			assert.Nil(t, err, "a zero exit is done")
+			assert.Equal(t, "", stderr)
+		})
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "web1", Project: "proj", Service: "web", Number: 1, Health: "unhealthy", Starting: 1},
+			{ID: "web2", Project: "proj", Service: "web", Number: 2, Health: "healthy"},
+			{ID: "db1", Project: "proj", Service: "db", Number: 1},
+			{ID: "cache1", Project: "proj", Service: "cache", Number: 1, State: "exited", ExitCode: 1},
+		}, func(t *testing.T) {
+			_, stderr, err := runTestCommand(cfg, []string{"up", "--wait", "web", "db", "queue"})
+
+			assert.Equal(t, "2 of 3 services did not become healthy", err.Error())
+			assert.Equal(t, "\nSERVICE  STATE\ndb       running\nqueue    not created\nweb      unhealthy\n", stderr)
+			assert.Equal(t, [][]string{{"up", "--detach", "web", "db", "queue"}}, fake.Args("docker-compose"))
+		})
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "web1", Project: "proj", Service: "web", Number: 1},
+			{ID: "migrate1", Project: "proj", Service: "migrate", Number: 1, State: "exited", ExitCode: 1},
+		}, func(t *testing.T) {
+			_, stderr, err := runTestCommand(cfg, []string{"up", "--wait"})
+
+			assert.Equal(t, "1 of 2 services did not become healthy", err.Error())
+			assert.Equal(t, "\nSERVICE  STATE\nmigrate  exited\nweb      running\n", stderr)
+		})
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		withFakeEngine(t, []testutil.FakeContainer{
+			{ID: "web1", Project: "proj", Service: "web", Number: 1, Health: "healthy", Starting: 1000},
+		}, func(t *testing.T) {
+			_, stderr, err := runTestCommand(cfg, []string{"up", "--wait", "--wait-timeout", "50ms"})
+
+			assert.Equal(t, "1 of 1 services did not become healthy within 50ms", err.Error())
+			assert.Equal(t, "\nSERVICE  STATE\nweb      starting\n", stderr)
+		})
+	})
+
+	t.Run("up fails", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+		fake.On("docker-compose", []string{"up"}, testutil.FakeResponse{ExitCode: 2})
+
+		withFakeEngine(t, nil, func(t *testing.T) {
+			_, _, err := runTestCommand(cfg, []string{"up", "--wait"})
+			assert.Equal(t, "exit status 2", err.Error())
+		})
+	})
+
+	t.Run("without engine", func(t *testing.T) {
+		fake := testutil.NewFakeBin(t, "docker-compose")
+		defer fake.Close()
+
+		_, _, err := runTestCommand(cfg, []string{"up", "--wait"})
+		assert.Equal(t, "--wait requires access to the container engine API: container engine is not available", err.Error())
+		assert.Empty(t, fake.Calls("docker-compose"), "nothing started")
+	})
+
+	t.Run("incompatible", func(t *testing.T) {
+		_, _, err := runTestCommand(cfg, []string{"up", "--wait", "--no-start"})
+		assert.Equal(t, "--wait is incompatible with --no-start, --abort-on-container-exit and --exit-code-from", err.Error())
+	})
+}
@@ -41,18 +41,22 @@ func newVersionCommand(_ *config.ProjectConfig) *cobra.Command {
 				}()
 			}
 
+			b := composeBackend()
+			container := b.Container()[0]
+
 			var dcVersion string
-			getVersion(&dcVersion, "docker-compose", "version", "--short")
+			getVersion(&dcVersion, b.Compose("version", "--short")...)
 
 			var dockerVersions string
-			getVersion(&dockerVersions, "docker", "version", "--format", `docker client {{ .Client.Version }}{{ "\n" }}docker server {{ .Server.Version }}`)
+			getVersion(&dockerVersions, b.Container("version", "--format", fmt.Sprintf(`%[1]s client {{ .Client.Version }}{{ "\n" }}%[1]s server {{ .Server.Version }}`, container))...)
 
 			wg.Wait()
 
 			fmt.Fprintf(
 				cmd.OutOrStdout(),
-				"muss %s\ndocker-compose %s\n%s\n",
+				"muss %s\n%s %s\n%s\n",
 				Version,
+				b.Name(),
 				dcVersion,
 				dockerVersions,
 			)
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gerrit.instructure.com/muss/engine"
+	"gerrit.instructure.com/muss/term"
+)
+
+// States of a service while waiting for it (from worst to best).
+const (
+	serviceUnhealthy  = "unhealthy"
+	serviceExited     = "exited"
+	serviceNotCreated = "not created"
+	serviceStarting   = "starting"
+	// Completed is a one-off task (like a migration) that exited successfully.
+	serviceCompleted = "completed"
+	serviceRunning   = "running"
+	serviceHealthy   = "healthy"
+)
+
+var serviceStateRank = map[string]int{
+	serviceUnhealthy:  0,
+	serviceExited:     1,
+	serviceNotCreated: 2,
+	serviceStarting:   3,
+	serviceCompleted:  4,
+	serviceRunning:    5,
+	serviceHealthy:    6,
+}
+
+// waitPollInterval can be shortened in tests.
+var waitPollInterval = time.Second
+
+// serviceState is the (worst) state of the containers of a service.
+type serviceState struct {
+	service string
+	state   string
+}
+
+// ready returns true if the service is healthy (or running without a healthcheck)
+// or has exited successfully.
+func (s serviceState) ready() bool {
+	return s.state == serviceHealthy || s.state == serviceRunning || s.state == serviceCompleted
+}
+
+// failed returns true if the service won't become ready without intervention.
+func (s serviceState) failed() bool {
+	return s.state == serviceUnhealthy || s.state == serviceExited
+}
+
+// containerState describes a container in terms of its healthcheck
+// (or whether it is running if it doesn't have one).
+func containerState(details *engine.ContainerDetails) string {
+	if !details.State.Running {
+		switch {
+		case details.State.Status == "created" || details.State.Status == "restarting":
+			return serviceStarting
+		case details.State.Status == "exited" && details.State.ExitCode == 0:
+			return serviceCompleted
+		}
+		return serviceExited
+	}
+	switch details.Health() {
+	case "":
+		return serviceRunning
+	case "healthy":
+		return serviceHealthy
+	case "unhealthy":
+		return serviceUnhealthy
+	default:
+		return serviceStarting
+	}
+}
+
+// checkServices returns the state of each of the services
+// (or of every service in the project if none are given).
+func checkServices(client *engine.Client, project string, services []string) ([]serviceState, error) {
+	containers, err := client.Containers(engine.Filter{Project: project})
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]string)
+	for _, name := range services {
+		states[name] = serviceNotCreated
+	}
+	for _, c := range containers {
+		name := c.Service()
+		current, seen := states[name]
+		// Skip services we aren't waiting for.
+		if len(services) > 0 && !seen {
+			continue
+		}
+		details, err := client.Inspect(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		// The service is only as ready as its least ready container.
+		state := containerState(details)
+		if !seen || current == serviceNotCreated || serviceStateRank[state] < serviceStateRank[current] {
+			states[name] = state
+		}
+	}
+
+	result := make([]serviceState, 0, len(states))
+	for name, state := range states {
+		result = append(result, serviceState{service: name, state: state})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].service < result[j].service })
+	return result, nil
+}
+
+// waitStatus summarizes the states for the status line.
+func waitStatus(states []serviceState) string {
+	ready := 0
+	waiting := make([]string, 0, len(states))
+	for _, s := range states {
+		if s.ready() {
+			ready++
+		} else {
+			waiting = append(waiting, fmt.Sprintf("%s (%s)", s.service, s.state))
+		}
+	}
+	return fmt.Sprintf("# muss: %d of %d services ready; waiting for %s", ready, len(states), strings.Join(waiting, ", "))
+}
+
+// waitForServices polls the services until they are all ready, any of them
+// fail, or the timeout (if not zero) passes, showing progress in a status line
+// (if showStatus is true).
+// If they don't all become ready it prints a report and returns an error.
+func waitForServices(stdout, stderr io.Writer, client *engine.Client, project string, services []string, timeout time.Duration, showStatus bool) error {
+	// Without a status line nothing reads the status
+	// (the buffer lets the first one through and the rest are dropped).
+	statusCh := make(chan string, 1)
+	stopStatus := func() {}
+	if showStatus {
+		done := make(chan bool)
+		finished := make(chan bool)
+		go func() {
+			term.WriteWithFixedStatusLine(stdout, make(chan []byte), statusCh, done)
+			close(finished)
+		}()
+		stopStatus = func() {
+			close(done)
+			<-finished
+		}
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	for {
+		states, err := checkServices(client, project, services)
+		if err != nil {
+			stopStatus()
+			return fmt.Errorf("failed to check services: %w", err)
+		}
+
+		if len(states) == 0 {
+			stopStatus()
+			return NewQuietError(fmt.Errorf("no services were started"))
+		}
+
+		failed := false
+		ready := true
+		for _, s := range states {
+			failed = failed || s.failed()
+			ready = ready && s.ready()
+		}
+		if ready {
+			stopStatus()
+			return nil
+		}
+
+		timedOut := false
+		if !failed {
+			select {
+			case statusCh <- waitStatus(states):
+			default:
+			}
+			select {
+			case <-deadline:
+				timedOut = true
+			case <-time.After(waitPollInterval):
+				continue
+			}
+		}
+
+		stopStatus()
+		return waitFailed(stderr, states, timedOut, timeout)
+	}
+}
+
+func waitFailed(w io.Writer, states []serviceState, timedOut bool, timeout time.Duration) error {
+	notReady := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tSTATE")
+	for _, s := range states {
+		if !s.ready() {
+			notReady++
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", s.service, s.state)
+	}
+
+	fmt.Fprintln(w)
+	tw.Flush()
+
+	msg := fmt.Sprintf("%d of %d services did not become healthy", notReady, len(states))
+	if timedOut {
+		msg += fmt.Sprintf(" within %s", timeout)
+	}
+	return NewQuietError(fmt.Errorf("%s", msg))
+}
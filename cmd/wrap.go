@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -18,6 +22,10 @@ func newWrapCommand(cfg *config.ProjectConfig) *cobra.Command {
 		shell = "/bin/sh"
 	}
 	useExec := false
+	mask := false
+	failFast := false
+	var gracePeriod time.Duration
+	processGroup := false
 
 	var cmd = &cobra.Command{
 		Use:   "wrap",
@@ -39,6 +47,16 @@ Usage: wrap [options] [COMMAND ARGS...]`,
 				if len(args) < 1 {
 					return fmt.Errorf("--exec requires a command")
 				}
+				if mask {
+					return fmt.Errorf("--exec and --mask are mutually exclusive")
+				}
+				if failFast {
+					return fmt.Errorf("--exec and --fail-fast are mutually exclusive")
+				}
+				// The command replaces muss so nothing can run after it.
+				if hooks, err := cfg.HooksFor(hookName("post", cmd)); err == nil && len(hooks) > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s hooks do not run with --exec\n", hookName("post", cmd))
+				}
 
 				return proc.Exec(args)
 			}
@@ -51,7 +69,20 @@ Usage: wrap [options] [COMMAND ARGS...]`,
 				commands = append(commands, exec.Command(shell, "-c", c))
 			}
 
-			return DelegateCmd(cmd, commands...)
+			delegator := cmdDelegator(cmd)
+			if mask {
+				if err := redactOutput(delegator); err != nil {
+					return err
+				}
+			}
+			delegator.FailFast = failFast
+			delegator.GracePeriod = gracePeriod
+			delegator.ProcessGroup = processGroup
+			err := delegator.Delegate(commands...)
+			if delegateErr, ok := err.(*proc.DelegateError); ok {
+				printDelegateSummary(cmd.ErrOrStderr(), delegateErr)
+			}
+			return err
 		},
 	}
 
@@ -63,12 +94,37 @@ Usage: wrap [options] [COMMAND ARGS...]`,
 		"Additional command (run by the shell).  Can be specified multiple times.")
 	cmd.Flags().BoolVarP(&useExec, "exec", "", false,
 		"Use exec instead of built-in command delegation (mutually exclusive with -c).")
+	cmd.Flags().BoolVarP(&mask, "mask", "", false,
+		"Mask secret values in the output of the commands (not available with --exec).")
+	cmd.Flags().BoolVarP(&failFast, "fail-fast", "", false,
+		"Stop the other commands (with SIGTERM) as soon as one fails.")
+	cmd.Flags().DurationVarP(&gracePeriod, "grace-period", "", 0,
+		"How long to wait for the commands to exit after a signal before sending\nSIGTERM (and then SIGKILL).  By default wait until Ctrl-C is pressed again.")
+	cmd.Flags().BoolVarP(&processGroup, "process-group", "", false,
+		"Run each command in its own process group and send signals to the whole group\n(the commands will not be able to read from the terminal).")
 	cmd.Flags().StringVarP(&shell, "shell", "s", shell,
 		"Shell to run -c commands (instead of $SHELL).\n")
 
 	return cmd
 }
 
+// printDelegateSummary shows how each command finished.
+func printDelegateSummary(w io.Writer, err *proc.DelegateError) {
+	fmt.Fprintf(w, "\n%d of %d commands failed:\n", len(err.Failed()), len(err.Results))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXIT\tDURATION\tCOMMAND")
+	for _, r := range err.Results {
+		exit := strconv.Itoa(r.ExitCode)
+		if r.Canceled {
+			exit = "canceled"
+		} else if r.ExitCode < 0 {
+			exit = "error"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", exit, r.Duration.Round(time.Millisecond), r)
+	}
+	tw.Flush()
+}
+
 func init() {
 	AddCommandBuilder(newWrapCommand)
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"syscall"
@@ -51,10 +52,72 @@ func TestWrapCommand(t *testing.T) {
 			expOut := "a\nb\n"
 
 			assert.Nil(t, err)
-			assert.Equal(t, "c\n", stderr)
+			assert.Regexp(t, `^muss: Waiting for "sh -c out .+" \(pid \d+\), "sh -c .+" \(pid \d+\) to exit \(press Ctrl-C again to stop now\)\.\.\.\nc\n$`, stderr)
 			assert.Equal(t, expOut, stdout, "got output from all")
 		})
 
+		t.Run("failure summary", func(t *testing.T) {
+			stdout, stderr, err := runTestCommand(nil, []string{
+				"wrap",
+				"-s", "/bin/sh",
+				"-c", "echo out",
+				"-c", "echo err >&2; exit 2",
+			})
+
+			assert.Equal(t, "out\n", stdout)
+			if assert.IsType(t, &proc.DelegateError{}, err) {
+				assert.Equal(t, 2, err.(*proc.DelegateError).ExitCode())
+			}
+
+			lines := strings.Split(stderr, "\n")
+			if assert.Len(t, lines, 7, stderr) {
+				assert.Equal(t, "err", lines[0])
+				assert.Equal(t, "", lines[1])
+				assert.Equal(t, "1 of 2 commands failed:", lines[2])
+				assert.Regexp(t, `^EXIT +DURATION +COMMAND$`, lines[3])
+				assert.Regexp(t, `^0 +\S+ +/bin/sh -c "echo out"$`, lines[4])
+				assert.Regexp(t, `^2 +\S+ +/bin/sh -c "echo err >&2; exit 2"$`, lines[5])
+			}
+		})
+
+		t.Run("fail fast", func(t *testing.T) {
+			start := time.Now()
+			stdout, stderr, err := runTestCommand(nil, []string{
+				"wrap",
+				"-s", "/bin/sh",
+				"--fail-fast",
+				"-c", "trap 'kill $!; exit 1' TERM; sleep 5 & wait; echo done",
+				"-c", "sleep 1; exit 4",
+			})
+
+			assert.True(t, time.Since(start) < 4*time.Second, "did not wait for sleep")
+			assert.Equal(t, "", stdout)
+			if assert.IsType(t, &proc.DelegateError{}, err) {
+				assert.Equal(t, 4, err.(*proc.DelegateError).ExitCode(), "exit code of the first failure")
+			}
+			assert.Regexp(t, `\ncanceled +\S+ +`+regexp.QuoteMeta(`/bin/sh -c "trap 'kill $!; exit 1' TERM; sleep 5 & wait; echo done"`)+`\n`, stderr)
+			assert.Regexp(t, `\n4 +\S+ +/bin/sh -c "sleep 1; exit 4"\n`, stderr)
+		})
+
+		t.Run("process group", func(t *testing.T) {
+			go func() {
+				time.Sleep(1 * time.Second)
+				syscall.Kill(os.Getpid(), syscall.SIGTERM)
+			}()
+
+			start := time.Now()
+			stdout, _, err := runTestCommand(nil, []string{
+				"wrap",
+				"--process-group",
+				"-s", "/bin/sh",
+				"-c", "sleep 5 & wait; echo done",
+			})
+
+			assert.True(t, time.Since(start) < 4*time.Second, "background process signaled")
+			assert.Equal(t, "signal: terminated", err.Error())
+			assert.Equal(t, "", stdout)
+		})
+
 		t.Run("exec", func(t *testing.T) {
 			stdout, stderr, err := runTestCommand(nil, []string{
 				"wrap",
@@ -66,6 +129,34 @@ func TestWrapCommand(t *testing.T) {
 			assert.Equal(t, "", stdout)
 
 			assert.Equal(t, []string{"echo", "foo"}, proc.LastExecArgv)
+
+			cfg := newTestConfig(t, map[string]interface{}{
+				"hooks": map[string]interface{}{
+					"post_wrap": []interface{}{map[string]interface{}{"exec": []interface{}{"true"}}},
+				},
+			})
+			_, stderr, err = runTestCommand(cfg, []string{"wrap", "--exec", "echo", "foo"})
+
+			assert.Nil(t, err)
+			assert.Contains(t, stderr, "Warning: post_wrap hooks do not run with --exec\n")
+		})
+
+		t.Run("mask", func(t *testing.T) {
+			os.Unsetenv("MUSS_TEST_WRAP_MASK")
+			defer os.Unsetenv("MUSS_TEST_WRAP_MASK")
+
+			cfg := newSecretTestConfig(t, "MUSS_TEST_WRAP_MASK", "shazam")
+
+			stdout, stderr, err := runTestCommand(cfg, []string{"wrap", "--mask", "-c", "echo $MUSS_TEST_WRAP_MASK"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "", stderr)
+			assert.Equal(t, "********\n", stdout)
+
+			stdout, _, err = runTestCommand(cfg, []string{"wrap", "-c", "echo $MUSS_TEST_WRAP_MASK"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "shazam\n", stdout, "not masked by default")
 		})
 
 		t.Run("shell", func(t *testing.T) {
@@ -102,6 +193,12 @@ func TestWrapCommand(t *testing.T) {
 			assert.Contains(t, errFromWrapCmd(t, "--exec"),
 				"--exec requires a command")
 
+			assert.Contains(t, errFromWrapCmd(t, "--mask", "--exec", "echo"),
+				"--exec and --mask are mutually exclusive")
+
+			assert.Contains(t, errFromWrapCmd(t, "--fail-fast", "--exec", "echo"),
+				"--exec and --fail-fast are mutually exclusive")
+
 		})
 	})
 }
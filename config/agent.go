@@ -0,0 +1,36 @@
+package config
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"gerrit.instructure.com/muss/agent"
+)
+
+// agentKey identifies a secret for the project and passphrase
+// without revealing either to the agent.
+func agentKey(passphrase []byte, args ...interface{}) string {
+	h := sha256.New()
+	h.Write([]byte(fmt.Sprintf("%#v", []interface{}{secretDir, string(passphrase), args})))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// agentGet returns the value from the agent (if one is running).
+func agentGet(key string) []byte {
+	value, found, err := agent.NewClient(agent.SocketPath()).Get(key)
+	if err != nil || !found {
+		return nil
+	}
+	return value
+}
+
+// agentSet stores the value in the agent (if one is running).
+func agentSet(key string, value []byte, ttl time.Duration) {
+	agent.NewClient(agent.SocketPath()).Set(key, value, ttl)
+}
+
+// agentLock removes all values from the agent (if one is running).
+func agentLock() {
+	agent.NewClient(agent.SocketPath()).Lock()
+}
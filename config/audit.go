@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gerrit.instructure.com/muss/proc"
+)
+
+// How the cache was used for an audited secret.
+const (
+	auditCacheHit      = "hit"
+	auditCacheMiss     = "miss"
+	auditCacheStale    = "stale"
+	auditCacheDisabled = "disabled"
+)
+
+// AuditEntry records a secret being loaded (but never its value).
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Project string    `json:"project"`
+	Name    string    `json:"name"`
+	Command string    `json:"command"`
+	Cache   string    `json:"cache"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// AuditFilter selects entries from the audit log.
+// Zero values match everything.
+type AuditFilter struct {
+	Project string
+	Name    string
+	Command string
+	Since   time.Time
+	Failed  bool
+}
+
+var auditMutex sync.Mutex
+
+// AuditLogFile returns the path of the audit log (shared by all projects).
+func AuditLogFile() string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		// secretDir is ".muss/<project>/secrets".
+		return path.Join(path.Dir(path.Dir(secretDir)), "audit.log")
+	}
+	return path.Join(cache, ".muss", "audit.log")
+}
+
+// AuditProject identifies the current project in the audit log.
+func AuditProject() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return filepath.Clean(wd)
+}
+
+func (s *secretCmd) auditEntry(cache string, err error) AuditEntry {
+	entry := AuditEntry{
+		Time:    time.Now(),
+		Project: s.project,
+		Name:    s.statusName(),
+		Command: s.name,
+		Cache:   cache,
+		Success: err == nil,
+	}
+	if err != nil {
+		// Just in case a command included a value in its error.
+		entry.Error = string(proc.Redact([]byte(err.Error()), SecretValues()))
+	}
+	return entry
+}
+
+// writeAuditEntry appends the entry to the audit log.
+// The audit log is best effort so that it never prevents using secrets.
+func writeAuditEntry(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	file := AuditLogFile()
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// ReadAuditLog returns the entries in the audit log that match the filter
+// (oldest first).
+func ReadAuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+
+	f, err := os.Open(AuditLogFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		// Skip anything unreadable (like a partially written line).
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	switch {
+	case f.Project != "" && e.Project != f.Project:
+		return false
+	case f.Name != "" && e.Name != f.Name:
+		return false
+	case f.Command != "" && e.Command != f.Command:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case f.Failed && e.Success:
+		return false
+	}
+	return true
+}
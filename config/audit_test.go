@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/proc"
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestAuditLog(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		findCacheRoot()
+		defer findCacheRoot()
+
+		os.Setenv("MUSS_TEST_PASSPHRASE", "audit")
+		defer os.Unsetenv("MUSS_TEST_PASSPHRASE")
+		os.Unsetenv("MUSS_TEST_AUDIT")
+		defer os.Unsetenv("MUSS_TEST_AUDIT")
+
+		cfg := &ProjectConfig{
+			SecretPassphrase: "$MUSS_TEST_PASSPHRASE",
+			SecretCommands: map[string]*SecretCommand{
+				"vault": &SecretCommand{Exec: []string{"echo"}},
+			},
+		}
+		spec := map[string]interface{}{
+			"vault":   []string{"audited-value"},
+			"varname": "MUSS_TEST_AUDIT",
+		}
+
+		secret, err := parseSecret(cfg, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLoadSecret(t, secret)
+		testutil.NoFileExists(t, AuditLogFile())
+
+		cfg.AuditLog = true
+		secret, err = parseSecret(cfg, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		os.Remove(secret.cacheFile())
+		start := time.Now()
+		for i := 0; i < 2; i++ {
+			os.Unsetenv("MUSS_TEST_AUDIT")
+			testLoadSecret(t, secret)
+		}
+
+		failing, err := parseSecret(cfg, map[string]interface{}{
+			"exec":    []string{"false"},
+			"varname": "MUSS_TEST_AUDIT_FAIL",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotNil(t, loadEnvFromCmds(failing))
+
+		assert.NotContains(t, testutil.ReadFile(t, AuditLogFile()), "audited-value", "values are not logged")
+
+		info, err := os.Stat(AuditLogFile())
+		if assert.Nil(t, err) {
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		}
+
+		entries, err := ReadAuditLog(AuditFilter{})
+		assert.Nil(t, err)
+		if assert.Equal(t, 3, len(entries)) {
+			project := AuditProject()
+
+			assert.Equal(t, project, entries[0].Project)
+			assert.Equal(t, "MUSS_TEST_AUDIT", entries[0].Name)
+			assert.Equal(t, "vault", entries[0].Command)
+			assert.Equal(t, "miss", entries[0].Cache)
+			assert.True(t, entries[0].Success)
+			assert.False(t, entries[0].Time.Before(start.Truncate(time.Second)))
+
+			assert.Equal(t, "hit", entries[1].Cache)
+
+			assert.Equal(t, "MUSS_TEST_AUDIT_FAIL", entries[2].Name)
+			assert.Equal(t, "exec", entries[2].Command)
+			assert.False(t, entries[2].Success)
+			assert.Equal(t, "failed to get secret: command failed: exit status 1", entries[2].Error)
+		}
+
+		entries, _ = ReadAuditLog(AuditFilter{Failed: true})
+		assert.Equal(t, 1, len(entries), "failed")
+
+		entries, _ = ReadAuditLog(AuditFilter{Name: "MUSS_TEST_AUDIT", Command: "vault"})
+		assert.Equal(t, 2, len(entries), "name and command")
+
+		entries, _ = ReadAuditLog(AuditFilter{Project: "/elsewhere"})
+		assert.Equal(t, 0, len(entries), "project")
+
+		entries, _ = ReadAuditLog(AuditFilter{Since: time.Now().Add(time.Minute)})
+		assert.Equal(t, 0, len(entries), "since")
+	})
+}
+
+func TestAuditEntryMasksErrors(t *testing.T) {
+	addSecretValue("audit-masked-value")
+
+	entry := (&secretCmd{
+		name:       "vault",
+		EnvCommand: &EnvCommand{Varname: "MUSS_TEST_AUDIT_MASK"},
+	}).auditEntry("none", errors.New("rejected audit-masked-value"))
+
+	assert.Equal(t, "rejected "+proc.RedactMask, entry.Error)
+}
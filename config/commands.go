@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Command is a project specific subcommand defined in the project config.
+type Command struct {
+	// Description is shown in help.
+	Description string `yaml:"description,omitempty"`
+	// Args names the args the command takes (shown in usage):
+	// "NAME" is required, "[NAME]" is optional, and "NAME..." (or "[NAME...]")
+	// takes the rest.  Without Args any args are passed along.
+	Args []string `yaml:"args,omitempty"`
+	// Exec is the command (and args) to run.
+	Exec []string `yaml:"exec,omitempty"`
+	// Script is a shell script to run (instead of Exec).
+	// The args are available as "$@".
+	Script string `yaml:"script,omitempty"`
+	// Service (if set) runs the command in the (running) container
+	// of that service rather than on the host.
+	Service string `yaml:"service,omitempty"`
+	// Needs lists services that must be running.
+	Needs []string `yaml:"needs,omitempty"`
+}
+
+var commandNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// Argv returns the command (without the args given on the command line).
+func (c *Command) Argv(name string) []string {
+	if c.Script != "" {
+		// The name becomes $0 for the script.
+		return []string{"sh", "-c", c.Script, name}
+	}
+	return append([]string{}, c.Exec...)
+}
+
+// ArgCount returns the minimum and maximum number of args
+// (max is -1 if there is no limit).
+func (c *Command) ArgCount() (int, int) {
+	if len(c.Args) == 0 {
+		return 0, -1
+	}
+	min, max := 0, 0
+	for _, arg := range c.Args {
+		if !strings.HasPrefix(arg, "[") {
+			min++
+		}
+		if isVariadic(arg) {
+			return min, -1
+		}
+		max++
+	}
+	return min, max
+}
+
+func isVariadic(arg string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(arg, "]"), "...")
+}
+
+func (c *Command) validate(name string) error {
+	if !commandNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid command name '%s'", name)
+	}
+	if c == nil || (len(c.Exec) == 0) == (c.Script == "") {
+		return fmt.Errorf("command %s must have one of exec or script", name)
+	}
+	for i, arg := range c.Args {
+		if isVariadic(arg) && i != len(c.Args)-1 {
+			return fmt.Errorf("invalid args for command %s; '%s' must be last", name, arg)
+		}
+	}
+	return nil
+}
+
+// CommandNames returns the names of the commands in the project config (sorted).
+func (cfg *ProjectConfig) CommandNames() []string {
+	names := make([]string, 0, len(cfg.Commands))
+	for name := range cfg.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (cfg *ProjectConfig) validateCommands() error {
+	for _, name := range cfg.CommandNames() {
+		if err := cfg.Commands[name].validate(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
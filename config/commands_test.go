@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommands(t *testing.T) {
+	t.Run("parsed", func(t *testing.T) {
+		parsed, err := parseYaml([]byte(`
+commands:
+  psql:
+    description: Open a database console
+    args: ["[DATABASE]"]
+    exec: [psql, -U, postgres]
+    service: db
+  migrate:
+    script: bin/migrate "$@"
+    needs: [db]
+`))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		cfg, err := NewConfigFromMap(parsed)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		assert.Equal(t, []string{"migrate", "psql"}, cfg.CommandNames())
+		assert.Equal(t, "Open a database console", cfg.Commands["psql"].Description)
+		assert.Equal(t, []string{"psql", "-U", "postgres"}, cfg.Commands["psql"].Argv("psql"))
+		assert.Equal(t, []string{"sh", "-c", `bin/migrate "$@"`, "migrate"}, cfg.Commands["migrate"].Argv("migrate"))
+		assert.Equal(t, []string{"db"}, cfg.Commands["migrate"].Needs)
+	})
+
+	t.Run("arg count", func(t *testing.T) {
+		count := func(args ...string) []int {
+			min, max := (&Command{Args: args}).ArgCount()
+			return []int{min, max}
+		}
+		assert.Equal(t, []int{0, -1}, count())
+		assert.Equal(t, []int{2, 2}, count("A", "B"))
+		assert.Equal(t, []int{1, 2}, count("A", "[B]"))
+		assert.Equal(t, []int{2, -1}, count("A", "B..."))
+		assert.Equal(t, []int{1, -1}, count("A", "[B...]"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assertConfigError(t, `
+commands:
+  -x:
+    exec: [echo]
+`,
+			"invalid command name '-x'",
+			"bad name")
+
+		assertConfigError(t, `
+commands:
+  both:
+    exec: [echo]
+    script: echo
+`,
+			"command both must have one of exec or script",
+			"exec and script")
+
+		assertConfigError(t, `
+commands:
+  neither:
+    description: nothing
+`,
+			"command neither must have one of exec or script",
+			"no exec or script")
+
+		assertConfigError(t, `
+commands:
+  rest:
+    exec: [echo]
+    args: [A..., B]
+`,
+			"invalid args for command rest; 'A...' must be last",
+			"variadic not last")
+	})
+}
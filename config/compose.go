@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -77,12 +78,15 @@ func (cfg *ProjectConfig) parseServiceDefinitions() (err error) {
 	}
 	files := make(FileGenMap)
 	secrets := make([]envLoader, 0)
+	hooks := make([]Hooks, 0)
+	defServices := make(map[string][]string)
 
 	for _, service := range cfg.ServiceDefinitions {
 		servconf, err := service.chooseConfig(cfg)
 		if err != nil {
 			return err
 		}
+		defServices[service.Name] = definedServices(servconf)
 
 		secretsToParse := make([]map[string]interface{}, 0)
 		if s, ok := servconf["secrets"]; ok {
@@ -116,6 +120,15 @@ func (cfg *ProjectConfig) parseServiceDefinitions() (err error) {
 			delete(servconf, "secrets")
 		}
 
+		if h, ok := servconf["hooks"]; ok {
+			parsed, err := parseHooks(service.Name, h)
+			if err != nil {
+				return err
+			}
+			hooks = append(hooks, parsed)
+			delete(servconf, "hooks")
+		}
+
 		dcc = mapMerge(dcc, servconf)
 	}
 
@@ -156,10 +169,27 @@ func (cfg *ProjectConfig) parseServiceDefinitions() (err error) {
 	cfg.composeConfig = dcc
 	cfg.filesToGenerate = files
 	cfg.Secrets = append(cfg.Secrets, secrets...)
+	cfg.serviceHooks = hooks
+	cfg.definitionServices = defServices
 
 	return nil
 }
 
+// definedServices returns the names of the services that the config runs
+// (rather than just adding to).
+func definedServices(servconf map[string]interface{}) []string {
+	names := make([]string, 0)
+	if services, ok := servconf["services"].(map[string]interface{}); ok {
+		for name, si := range services {
+			if service, ok := si.(map[string]interface{}); ok && isValidService(service) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func isValidService(service map[string]interface{}) bool {
 	if _, ok := service["build"]; ok {
 		return true
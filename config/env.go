@@ -4,22 +4,41 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"sync"
+	"regexp"
+	"strings"
+	"time"
 )
 
 // EnvCommand is a command that sets (an) env var(s).
 type EnvCommand struct {
-	Exec    []string `yaml:"exec"`
-	Parse   bool     `yaml:"parse"`
-	Varname string   `yaml:"varname"`
+	Exec []string `yaml:"exec"`
+	// Parse can be true (for "NAME=VALUE" lines) or "json", "yaml", or "dotenv"
+	// (false or unset uses the output as the value of varname).
+	Parse   interface{}       `yaml:"parse"`
+	Varname string            `yaml:"varname"`
+	Field   string            `yaml:"field,omitempty"`
+	Fields  map[string]string `yaml:"fields,omitempty"`
+	Timeout time.Duration     `yaml:"timeout,omitempty"`
+	// Retries is a pointer so that "retries: 0" can override the default.
+	Retries *int `yaml:"retries,omitempty"`
+	// Names of env vars (set by other commands) that this command uses.
+	Needs []string `yaml:"needs,omitempty"`
 }
 
 type envLoader interface {
-	ShouldParse() bool
+	ParseFormat() (string, error)
+	FieldName() string
+	FieldMap() map[string]string
 	Value() ([]byte, error)
 	VarName() string
+	NeededVars() []string
+	ProvidedVars() ([]string, bool)
+	description() string
+	// secret is true if the values should be masked in output.
+	secret() bool
 }
 
 // LoadEnv will load environment variables from all config sources
@@ -40,38 +59,214 @@ func (cfg *ProjectConfig) LoadEnv() error {
 	return nil
 }
 
-// ShouldParse is true if the output should be parsed and false if varname
-// should be used.
-func (e *EnvCommand) ShouldParse() bool {
-	return e.Parse
+// MarshalYAML shows an unset "parse" as false.
+func (e *EnvCommand) MarshalYAML() (interface{}, error) {
+	type plain EnvCommand
+	c := plain(*e)
+	if c.Parse == nil {
+		c.Parse = false
+	}
+	return c, nil
+}
+
+// ParseFormat returns the format of the output that should be parsed
+// or an empty string if the output is the value for varname.
+func (e *EnvCommand) ParseFormat() (string, error) {
+	return parseFormat(e.Parse)
+}
+
+// FieldName returns the field of the parsed output to use for varname.
+func (e *EnvCommand) FieldName() string {
+	return e.Field
+}
+
+// FieldMap returns a map of fields of the parsed output to env var names.
+func (e *EnvCommand) FieldMap() map[string]string {
+	return e.Fields
 }
 
 // Value will run the command and return the output.
+// The command will be run again (up to Retries times) if it fails.
 func (e *EnvCommand) Value() ([]byte, error) {
-	var stdout bytes.Buffer
+	var err error
+	retries := 0
+	if e.Retries != nil {
+		retries = *e.Retries
+	}
+	for attempt := 0; attempt <= retries; attempt++ {
+		var output []byte
+		if output, err = e.run(); err == nil {
+			return output, nil
+		}
+		// Trying again won't get it any input.
+		var inputErr *needsInputError
+		if errors.As(err, &inputErr) {
+			return nil, fmt.Errorf("%s failed in non-interactive mode (it may need input): %w", e.description(), inputErr.err)
+		}
+	}
+	return nil, err
+}
+
+// needsInputError is returned when a command fails in non-interactive mode
+// after trying to read input.
+type needsInputError struct {
+	err error
+}
+
+func (e *needsInputError) Error() string {
+	return e.err.Error()
+}
+
+// reNeedsInput matches the errors that commands print when they can't
+// read input (stdin is empty and there is no terminal in non-interactive mode):
+// failing to open /dev/tty (or finding stdin isn't one)
+// or reaching the end of stdin.
+var reNeedsInput = regexp.MustCompile(`(?i)/dev/tty|\bnot a (tty|terminal)\b|\bterminal is required\b|inappropriate ioctl for device|\b(stdin|standard input)\b[^\n]*\b(EOF|end of file)\b|\b(EOF|end of file)\b[^\n]*\b(stdin|standard input)\b`)
+
+func (e *EnvCommand) run() ([]byte, error) {
+	var stdout, stderr bytes.Buffer
 	cmd := exec.Command(e.Exec[0], e.Exec[1:]...)
-	cmd.Stdin = os.Stdin
 	cmd.Stdout = &stdout
 	// Pass stderr to show password prompts (or any problems).
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	var waitCh <-chan error
+	var err error
+	interactive := !nonInteractive()
+	if interactive {
+		cmd.Stdin = os.Stdin
+		// In its own process group so that a timeout can kill
+		// anything it starts.
+		waitCh, err = startProcessGroup(cmd, os.Stdin)
+	} else {
+		// Leave stdin empty and detach from the terminal
+		// so that anything waiting for input fails rather than hanging.
+		// The new session is also a new process group.
+		detachTerminal(cmd)
+		// Keep the errors to see if it wanted input.
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		if err = cmd.Start(); err == nil {
+			ch := make(chan error, 1)
+			go func() {
+				ch <- cmd.Wait()
+			}()
+			waitCh = ch
+		}
+	}
+	if err != nil {
 		return nil, fmt.Errorf("command failed: %s", err)
 	}
 
+	var timeoutCh <-chan time.Time
+	if e.Timeout > 0 {
+		timer := time.NewTimer(e.Timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case err := <-waitCh:
+		if err != nil {
+			err = fmt.Errorf("command failed: %s", err)
+			if !interactive && reNeedsInput.Match(stderr.Bytes()) {
+				return nil, &needsInputError{err: err}
+			}
+			return nil, err
+		}
+	case <-timeoutCh:
+		// Kill the whole group since any child processes
+		// may still hold stdout open (which Wait() waits for).
+		killProcessGroup(cmd.Process)
+		return nil, fmt.Errorf("command timed out after %s", e.Timeout)
+	}
+
 	return bytes.TrimRight(stdout.Bytes(), "\n"), nil
 }
 
+// description names the command for error messages.
+func (e *EnvCommand) description() string {
+	if e.Varname != "" {
+		return e.Varname
+	}
+	return fmt.Sprintf("%q", strings.Join(e.Exec, " "))
+}
+
+// withDefaults returns a copy of the command
+// using the provided timeout and retries if it doesn't set its own.
+func (e *EnvCommand) withDefaults(timeout time.Duration, retries int) *EnvCommand {
+	c := *e
+	if c.Timeout == 0 {
+		c.Timeout = timeout
+	}
+	if c.Retries == nil {
+		c.Retries = &retries
+	}
+	return &c
+}
+
+// secret is false since env commands only set up secret commands
+// (their values are not masked).
+func (e *EnvCommand) secret() bool {
+	return false
+}
+
+// nonInteractive returns true if commands should not expect any user input.
+func nonInteractive() bool {
+	switch os.Getenv("MUSS_NONINTERACTIVE") {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
 // VarName returns the name of the env var that the command will set.
 func (e *EnvCommand) VarName() string {
 	return e.Varname
 }
 
+// NeededVars returns the names of the env vars that must be set first.
+func (e *EnvCommand) NeededVars() []string {
+	return e.Needs
+}
+
+// ProvidedVars returns the names of the env vars that the command will set
+// and false if they can't be known without running it.
+func (e *EnvCommand) ProvidedVars() ([]string, bool) {
+	if e.Varname != "" {
+		return []string{e.Varname}, true
+	}
+	if len(e.Fields) > 0 {
+		vars := make([]string, 0, len(e.Fields))
+		for _, k := range sortedKeys(e.Fields) {
+			vars = append(vars, e.Fields[k])
+		}
+		return vars, true
+	}
+	return nil, false
+}
+
 func loadEnv(e envLoader) error {
+	format, err := e.ParseFormat()
+	if err != nil {
+		return err
+	}
+	field := e.FieldName()
+	fields := e.FieldMap()
+
+	if format == "" && (field != "" || len(fields) > 0) {
+		return fmt.Errorf(`"field" and "fields" require "parse"`)
+	}
+	if field != "" && len(fields) > 0 {
+		return fmt.Errorf(`use "field" or "fields", not both`)
+	}
+
 	// For a single value...
-	if !e.ShouldParse() {
+	if format == "" || field != "" {
 		varname := e.VarName()
 		if varname == "" {
+			if field != "" {
+				return fmt.Errorf(`"field" requires a "varname"`)
+			}
 			return fmt.Errorf(`env command must have either "parse: true" or a "varname"`)
 		}
 		// Only get it if not already set.
@@ -80,9 +275,23 @@ func loadEnv(e envLoader) error {
 			if err != nil {
 				return err
 			}
-			if err := os.Setenv(varname, string(val)); err != nil {
+			value := string(val)
+			if field != "" {
+				parsed, err := parseEnvOutput(format, val)
+				if err != nil {
+					return err
+				}
+				if value, err = lookupField(parsed, field); err != nil {
+					return err
+				}
+			}
+			if err := os.Setenv(varname, value); err != nil {
 				return err
 			}
+			if e.secret() {
+				addSecretValue(value)
+			}
+			addLoadedVar(varname)
 		}
 	} else {
 		if e.VarName() != "" {
@@ -94,31 +303,52 @@ func loadEnv(e envLoader) error {
 		if err != nil {
 			return err
 		}
-		return loadEnvFromBytes(val)
+		parsed, err := parseEnvOutput(format, val)
+		if err != nil {
+			return err
+		}
+
+		var env map[string]string
+		if len(fields) > 0 {
+			env = make(map[string]string, len(fields))
+			for field, varname := range fields {
+				if env[varname], err = lookupField(parsed, field); err != nil {
+					return err
+				}
+			}
+		} else if env, err = envFromParsed(parsed); err != nil {
+			return err
+		}
+
+		for _, name := range sortedKeys(env) {
+			addProvidedVar(name)
+			// Only track the values that are actually used.
+			if _, ok := os.LookupEnv(name); ok {
+				continue
+			}
+			if err := os.Setenv(name, env[name]); err != nil {
+				return err
+			}
+			if e.secret() {
+				addSecretValue(env[name])
+			}
+			addLoadedVar(name)
+		}
 	}
 	return nil
 }
 
 // loadEnvFromCmds takes envLoaders and runs them and updates the current env.
+// Loaders run in parallel unless they need vars that other loaders set.
 func loadEnvFromCmds(envCmds ...envLoader) error {
-	cmdErrors := make(chan error, len(envCmds))
-	var wg sync.WaitGroup
-	for _, env := range envCmds {
-		wg.Add(1)
-		go func(env envLoader) {
-			defer wg.Done()
-			err := loadEnv(env)
-			if err != nil {
-				cmdErrors <- err
-			}
-		}(env)
+	nodes, err := buildEnvGraph(envCmds)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	close(cmdErrors)
 
-	if len(cmdErrors) > 0 {
+	if cmdErrors := runEnvGraph(nodes); len(cmdErrors) > 0 {
 		var errorMessage string
-		for e := range cmdErrors {
+		for _, e := range cmdErrors {
 			errorMessage += e.Error()
 		}
 		return errors.New(errorMessage)
@@ -126,25 +356,6 @@ func loadEnvFromCmds(envCmds ...envLoader) error {
 	return nil
 }
 
-func loadEnvFromBytes(env []byte) error {
-	lines := bytes.Split(env, []byte("\n"))
-
-	for _, line := range lines {
-		if len(line) == 0 {
-			continue
-		}
-
-		parts := bytes.SplitN(line, []byte("="), 2)
-		if len(parts) != 2 {
-			return fmt.Errorf("failed to parse name=value line: %s", line)
-		}
-
-		setenvIfUnset(string(parts[0]), string(parts[1]))
-	}
-
-	return nil
-}
-
 func setenvIfUnset(key string, value string) (err error) {
 	if _, ok := os.LookupEnv(key); !ok {
 		err = os.Setenv(key, value)
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// envNode is an envLoader in the dependency graph.
+type envNode struct {
+	loader envLoader
+	needs  []string
+	deps   []*envNode
+	done   chan struct{}
+	err    error
+}
+
+// errDependencyFailed marks loaders that were skipped
+// because something they need failed (which is reported on its own).
+var errDependencyFailed = errors.New("dependency failed")
+
+// buildEnvGraph connects each loader to the loaders that set the vars it needs.
+// Loaders that parse output without "fields" could set anything
+// so a var that nothing else sets is assumed to come from one of them.
+func buildEnvGraph(loaders []envLoader) ([]*envNode, error) {
+	nodes := make([]*envNode, len(loaders))
+	providers := make(map[string][]*envNode)
+	dynamic := make([]*envNode, 0)
+
+	for i, loader := range loaders {
+		n := &envNode{
+			loader: loader,
+			needs:  loader.NeededVars(),
+			done:   make(chan struct{}),
+		}
+		nodes[i] = n
+
+		if vars, known := loader.ProvidedVars(); known {
+			for _, v := range vars {
+				providers[v] = append(providers[v], n)
+			}
+		} else {
+			dynamic = append(dynamic, n)
+		}
+	}
+
+	for _, n := range nodes {
+		for _, need := range n.needs {
+			if p, ok := providers[need]; ok {
+				n.deps = append(n.deps, p...)
+				continue
+			}
+			// Already available.
+			if _, ok := os.LookupEnv(need); ok {
+				continue
+			}
+			found := false
+			for _, d := range dynamic {
+				if d != n {
+					n.deps = append(n.deps, d)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s needs %s but nothing sets it", n.loader.description(), need)
+			}
+		}
+	}
+
+	if err := checkEnvCycles(nodes); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// checkEnvCycles returns an error describing the first cycle it finds.
+func checkEnvCycles(nodes []*envNode) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*envNode]int, len(nodes))
+	path := make([]*envNode, 0)
+
+	var visit func(n *envNode) error
+	visit = func(n *envNode) error {
+		switch state[n] {
+		case visited:
+			return nil
+		case visiting:
+			// Show the cycle starting and ending with this node.
+			names := make([]string, 0)
+			for i := len(path) - 1; i >= 0; i-- {
+				names = append([]string{path[i].loader.description()}, names...)
+				if path[i] == n {
+					break
+				}
+			}
+			names = append(names, n.loader.description())
+			return fmt.Errorf("dependency cycle: %s", strings.Join(names, " -> "))
+		}
+
+		state[n] = visiting
+		path = append(path, n)
+		for _, d := range n.deps {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+		return nil
+	}
+
+	for _, n := range nodes {
+		if err := visit(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run loads the env once everything it needs has loaded.
+func (n *envNode) run() error {
+	defer close(n.done)
+
+	for _, d := range n.deps {
+		<-d.done
+		if d.err != nil {
+			n.err = errDependencyFailed
+			return nil
+		}
+	}
+
+	for _, need := range n.needs {
+		if _, ok := os.LookupEnv(need); !ok {
+			n.err = fmt.Errorf("%s needs %s but it was not set", n.loader.description(), need)
+			return n.err
+		}
+	}
+
+	n.err = loadEnv(n.loader)
+	return n.err
+}
+
+// runEnvGraph runs each loader as soon as its dependencies have finished
+// (so independent loaders run in parallel).
+func runEnvGraph(nodes []*envNode) []error {
+	cmdErrors := make(chan error, len(nodes))
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *envNode) {
+			defer wg.Done()
+			if err := n.run(); err != nil {
+				cmdErrors <- err
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(cmdErrors)
+
+	errs := make([]error, 0, len(cmdErrors))
+	for e := range cmdErrors {
+		errs = append(errs, e)
+	}
+	return errs
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvDependencies(t *testing.T) {
+	vars := []string{"MUSS_TEST_LOGIN", "MUSS_TEST_TOKEN", "MUSS_TEST_SECRET", "MUSS_TEST_OTHER", "MUSS_TEST_DYN", "MUSS_TEST_A", "MUSS_TEST_B"}
+	unset := func() {
+		for _, v := range vars {
+			os.Unsetenv(v)
+		}
+	}
+	unset()
+	defer unset()
+
+	sh := func(script string) []string {
+		return []string{"/bin/sh", "-c", script}
+	}
+
+	t.Run("ordering", func(t *testing.T) {
+		defer unset()
+
+		start := time.Now()
+		err := loadEnvFromCmds(
+			&EnvCommand{
+				Exec:    sh(`echo "$MUSS_TEST_TOKEN/secret"`),
+				Varname: "MUSS_TEST_SECRET",
+				Needs:   []string{"MUSS_TEST_TOKEN"},
+			},
+			&EnvCommand{
+				Exec:  sh(`echo "{\"token\": \"$MUSS_TEST_LOGIN/token\"}"`),
+				Parse: "json",
+				Fields: map[string]string{
+					"token": "MUSS_TEST_TOKEN",
+				},
+				Needs: []string{"MUSS_TEST_LOGIN"},
+			},
+			&EnvCommand{
+				Exec:    sh(`sleep 0.5; echo login`),
+				Varname: "MUSS_TEST_LOGIN",
+			},
+			&EnvCommand{
+				Exec:    sh(`sleep 0.5; echo other`),
+				Varname: "MUSS_TEST_OTHER",
+			},
+		)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "login/token/secret", os.Getenv("MUSS_TEST_SECRET"))
+		assert.Equal(t, "other", os.Getenv("MUSS_TEST_OTHER"))
+		assert.True(t, time.Since(start) < 900*time.Millisecond, "independent commands run in parallel")
+	})
+
+	t.Run("parsed output", func(t *testing.T) {
+		defer unset()
+
+		err := loadEnvFromCmds(
+			&EnvCommand{
+				Exec:    sh(`echo "$MUSS_TEST_DYN!"`),
+				Varname: "MUSS_TEST_A",
+				Needs:   []string{"MUSS_TEST_DYN"},
+			},
+			&EnvCommand{
+				Exec:  sh(`sleep 0.1; echo MUSS_TEST_DYN=dyn`),
+				Parse: true,
+			},
+		)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "dyn!", os.Getenv("MUSS_TEST_A"), "waits for commands that parse output")
+
+		os.Unsetenv("MUSS_TEST_A")
+		err = loadEnvFromCmds(
+			&EnvCommand{
+				Exec:    sh(`echo a`),
+				Varname: "MUSS_TEST_A",
+				Needs:   []string{"MUSS_TEST_B"},
+			},
+			&EnvCommand{
+				Exec:  sh(`echo MUSS_TEST_DYN=dyn`),
+				Parse: true,
+			},
+		)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "MUSS_TEST_A needs MUSS_TEST_B but it was not set", err.Error())
+		}
+	})
+
+	t.Run("already set", func(t *testing.T) {
+		defer unset()
+
+		os.Setenv("MUSS_TEST_B", "b")
+		err := loadEnvFromCmds(&EnvCommand{
+			Exec:    sh(`echo "$MUSS_TEST_B"`),
+			Varname: "MUSS_TEST_A",
+			Needs:   []string{"MUSS_TEST_B"},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "b", os.Getenv("MUSS_TEST_A"))
+	})
+
+	t.Run("failed dependency", func(t *testing.T) {
+		defer unset()
+
+		err := loadEnvFromCmds(
+			&EnvCommand{
+				Exec:    sh(`echo b`),
+				Varname: "MUSS_TEST_B",
+				Needs:   []string{"MUSS_TEST_A"},
+			},
+			&EnvCommand{
+				Exec:    []string{"false"},
+				Varname: "MUSS_TEST_A",
+			},
+		)
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "command failed: exit status 1", err.Error(), "only the failure is reported")
+		}
+		assert.True(t, envIsUnset("MUSS_TEST_B"), "dependent skipped")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		defer unset()
+
+		err := loadEnvFromCmds(
+			&EnvCommand{Exec: sh("echo"), Varname: "MUSS_TEST_A", Needs: []string{"MUSS_TEST_B"}},
+			&EnvCommand{Exec: sh("echo"), Varname: "MUSS_TEST_B", Needs: []string{"MUSS_TEST_OTHER"}},
+			&EnvCommand{Exec: sh("echo"), Varname: "MUSS_TEST_OTHER", Needs: []string{"MUSS_TEST_A"}},
+		)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "dependency cycle: MUSS_TEST_A -> MUSS_TEST_B -> MUSS_TEST_OTHER -> MUSS_TEST_A", err.Error())
+		}
+
+		err = loadEnvFromCmds(
+			&EnvCommand{Exec: sh("echo"), Varname: "MUSS_TEST_A", Needs: []string{"MUSS_TEST_A"}},
+		)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "dependency cycle: MUSS_TEST_A -> MUSS_TEST_A", err.Error())
+		}
+
+		err = loadEnvFromCmds(
+			&EnvCommand{Exec: sh("echo"), Varname: "MUSS_TEST_A", Needs: []string{"MUSS_TEST_NOPE"}},
+		)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "MUSS_TEST_A needs MUSS_TEST_NOPE but nothing sets it", err.Error())
+		}
+
+		assert.True(t, envIsUnset("MUSS_TEST_A"), "nothing runs")
+	})
+}
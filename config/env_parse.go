@@ -0,0 +1,293 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Output formats that env commands can parse.
+const (
+	parseLines  = "lines"
+	parseJSON   = "json"
+	parseYAML   = "yaml"
+	parseDotenv = "dotenv"
+)
+
+// parseFormat normalizes the value of a "parse" option:
+// true means the original "NAME=VALUE" lines.
+func parseFormat(parse interface{}) (string, error) {
+	switch p := parse.(type) {
+	case nil:
+		return "", nil
+	case bool:
+		if p {
+			return parseLines, nil
+		}
+		return "", nil
+	case string:
+		switch p {
+		case "":
+			return "", nil
+		case parseLines, parseJSON, parseYAML, parseDotenv:
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf(`invalid value for "parse": %v (must be true, "json", "yaml", or "dotenv")`, parse)
+}
+
+// parseEnvOutput parses command output in the specified format
+// and returns a map of the top level keys.
+func parseEnvOutput(format string, output []byte) (map[string]interface{}, error) {
+	switch format {
+	case parseLines:
+		return parseLinesOutput(output)
+	case parseDotenv:
+		return parseDotenvOutput(output)
+	case parseJSON:
+		var obj interface{}
+		decoder := json.NewDecoder(bytes.NewReader(output))
+		// Keep numbers as they were written.
+		decoder.UseNumber()
+		if err := decoder.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("failed to parse json output: %s", err)
+		}
+		if m, ok := obj.(map[string]interface{}); ok {
+			return m, nil
+		}
+		return nil, fmt.Errorf("failed to parse json output: expected an object")
+	case parseYAML:
+		var obj interface{}
+		if err := yaml.Unmarshal(output, &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml output: %s", err)
+		}
+		if m, ok := normalizeYamlValue(obj).(map[string]interface{}); ok {
+			return m, nil
+		}
+		return nil, fmt.Errorf("failed to parse yaml output: expected a map")
+	}
+	return nil, fmt.Errorf("unknown parse format %q", format)
+}
+
+// normalizeYamlValue recursively converts yaml maps to use string keys
+// so that yaml and json output can be treated the same.
+func normalizeYamlValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprintf("%v", k)] = normalizeYamlValue(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, item := range val {
+			s[i] = normalizeYamlValue(item)
+		}
+		return s
+	}
+	return v
+}
+
+// lookupField finds a dot-separated path (like "data.password")
+// in parsed output.  List items can be selected by index ("keys.0").
+func lookupField(obj map[string]interface{}, field string) (string, error) {
+	var current interface{} = obj
+	for _, key := range strings.Split(field, ".") {
+		switch val := current.(type) {
+		case map[string]interface{}:
+			next, ok := val[key]
+			if !ok {
+				return "", fmt.Errorf("field %q not found in output", field)
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(val) {
+				return "", fmt.Errorf("field %q not found in output", field)
+			}
+			current = val[i]
+		default:
+			return "", fmt.Errorf("field %q not found in output", field)
+		}
+	}
+
+	value, ok := scalarString(current)
+	if !ok {
+		return "", fmt.Errorf("field %q is not a string, number, or boolean", field)
+	}
+	return value, nil
+}
+
+// envFromParsed returns the env vars from the top level of parsed output.
+func envFromParsed(obj map[string]interface{}) (map[string]string, error) {
+	env := make(map[string]string, len(obj))
+	for k, v := range obj {
+		value, ok := scalarString(v)
+		if !ok {
+			return nil, fmt.Errorf(`value for %q is not a string, number, or boolean (use "field" or "fields" to select nested values)`, k)
+		}
+		env[k] = value
+	}
+	return env, nil
+}
+
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return val, true
+	case json.Number:
+		return val.String(), true
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprintf("%v", val), true
+	}
+	return "", false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseLinesOutput parses simple "NAME=VALUE" lines (no quoting).
+func parseLinesOutput(env []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	lines := bytes.Split(env, []byte("\n"))
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		parts := bytes.SplitN(line, []byte("="), 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("failed to parse name=value line: %s", line)
+		}
+
+		result[string(parts[0])] = string(parts[1])
+	}
+
+	return result, nil
+}
+
+var reDotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// parseDotenvOutput parses dotenv formatted output:
+//
+//   # comments and blank lines are ignored
+//   export NAME=value       # "export" is optional, trailing comments removed
+//   SINGLE='literal $value' # no escapes
+//   DOUBLE="line 1\nline 2" # \n, \r, \t, \", \\ and \$ are unescaped
+//   MULTI="first
+//   second"                 # quoted values can span lines
+func parseDotenvOutput(env []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	src := string(env)
+	lineNum := 0
+
+	for len(src) > 0 {
+		lineNum++
+
+		var line string
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			line, src = src[:i], src[i+1:]
+		} else {
+			line, src = src, ""
+		}
+
+		// Only trim the left side so that a quoted value keeps its whitespace.
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.TrimSpace(trimmed) == "" || trimmed[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "export ") {
+			trimmed = strings.TrimLeft(trimmed[len("export "):], " \t")
+		}
+
+		eq := strings.IndexByte(trimmed, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("dotenv line %d: missing '=': %s", lineNum, trimmed)
+		}
+		name := strings.TrimSpace(trimmed[:eq])
+		if !reDotenvName.MatchString(name) {
+			return nil, fmt.Errorf("dotenv line %d: invalid variable name %q", lineNum, name)
+		}
+		rest := strings.TrimLeft(trimmed[eq+1:], " \t")
+
+		if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+			quote := rest[0]
+			value, remaining, consumed, ok := dotenvQuoted(rest[1:]+"\n"+src, quote)
+			if !ok {
+				return nil, fmt.Errorf("dotenv line %d: unterminated quoted value for %s", lineNum, name)
+			}
+			// Count any additional lines the value spanned.
+			lineNum += strings.Count(consumed, "\n")
+			// Anything after the closing quote must be a comment.
+			after := remaining
+			if i := strings.IndexByte(remaining, '\n'); i >= 0 {
+				after, src = remaining[:i], remaining[i+1:]
+			} else {
+				src = ""
+			}
+			if after = strings.TrimSpace(after); after != "" && after[0] != '#' {
+				return nil, fmt.Errorf("dotenv line %d: unexpected characters after quoted value for %s", lineNum, name)
+			}
+			result[name] = value
+			continue
+		}
+
+		// Unquoted values end at a comment (a "#" preceded by whitespace).
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '#' && (rest[i-1] == ' ' || rest[i-1] == '\t') {
+				rest = rest[:i]
+				break
+			}
+		}
+		result[name] = strings.TrimSpace(rest)
+	}
+
+	return result, nil
+}
+
+// dotenvQuoted reads a quoted value (after the opening quote) and returns the
+// value, the input remaining after the closing quote, and the consumed input.
+func dotenvQuoted(s string, quote byte) (value, remaining, consumed string, ok bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == quote {
+			return b.String(), s[i+1:], s[:i], true
+		}
+		if c == '\\' && quote == '"' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return "", "", "", false
+}
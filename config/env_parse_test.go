@@ -0,0 +1,134 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFormat(t *testing.T) {
+	for input, exp := range map[interface{}]string{
+		nil:      "",
+		false:    "",
+		true:     "lines",
+		"":       "",
+		"json":   "json",
+		"yaml":   "yaml",
+		"dotenv": "dotenv",
+	} {
+		format, err := parseFormat(input)
+		assert.Nil(t, err)
+		assert.Equal(t, exp, format, "parse: %v", input)
+	}
+
+	_, err := parseFormat("xml")
+	assert.Equal(t, `invalid value for "parse": xml (must be true, "json", "yaml", or "dotenv")`, err.Error())
+}
+
+func TestParseEnvOutput(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		parsed, err := parseEnvOutput("json", []byte(`{"user": "bob", "port": 5432, "ok": true, "data": {"password": "pw", "keys": ["a", "b"]}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := lookupField(parsed, "data.password")
+		assert.Nil(t, err)
+		assert.Equal(t, "pw", value)
+
+		value, err = lookupField(parsed, "data.keys.1")
+		assert.Nil(t, err)
+		assert.Equal(t, "b", value, "list index")
+
+		value, err = lookupField(parsed, "port")
+		assert.Nil(t, err)
+		assert.Equal(t, "5432", value, "number")
+
+		_, err = lookupField(parsed, "data.nope")
+		assert.Equal(t, `field "data.nope" not found in output`, err.Error())
+
+		_, err = lookupField(parsed, "data")
+		assert.Equal(t, `field "data" is not a string, number, or boolean`, err.Error())
+
+		_, err = envFromParsed(parsed)
+		assert.Equal(t, `value for "data" is not a string, number, or boolean (use "field" or "fields" to select nested values)`, err.Error())
+
+		delete(parsed, "data")
+		env, err := envFromParsed(parsed)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"user": "bob", "port": "5432", "ok": "true"}, env)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		parsed, err := parseEnvOutput("yaml", []byte("data:\n  password: pw\n  list:\n    - {x: 1}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := lookupField(parsed, "data.list.0.x")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", value)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := parseEnvOutput("json", []byte(`{"a": `))
+		assert.Equal(t, "failed to parse json output: unexpected EOF", err.Error())
+
+		_, err = parseEnvOutput("json", []byte(`["a"]`))
+		assert.Equal(t, "failed to parse json output: expected an object", err.Error())
+
+		_, err = parseEnvOutput("yaml", []byte("- a\n"))
+		assert.Equal(t, "failed to parse yaml output: expected a map", err.Error())
+
+		_, err = parseEnvOutput("yaml", []byte("a: [\n"))
+		assert.Contains(t, err.Error(), "failed to parse yaml output: yaml: ")
+	})
+}
+
+func TestParseDotenv(t *testing.T) {
+	parsed, err := parseEnvOutput("dotenv", []byte(`
+# a comment
+export EXPORTED=yes
+PLAIN = some value  # trailing comment
+HASH=no#comment
+EMPTY=
+SINGLE='literal $HOME \n # not a comment'
+DOUBLE="tab\there \"quoted\" \\ \$HOME"
+MULTI="line 1
+line 2  "
+SINGLE_MULTI='a
+b'  # comment after quote
+CRLF=windows`+"\r"+`
+LAST="no newline"`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"EXPORTED":     "yes",
+		"PLAIN":        "some value",
+		"HASH":         "no#comment",
+		"EMPTY":        "",
+		"SINGLE":       `literal $HOME \n # not a comment`,
+		"DOUBLE":       "tab\there \"quoted\" \\ $HOME",
+		"MULTI":        "line 1\nline 2  ",
+		"SINGLE_MULTI": "a\nb",
+		"CRLF":         "windows",
+		"LAST":         "no newline",
+	}, parsed)
+
+	errors := map[string]string{
+		"FOO":                  "dotenv line 1: missing '=': FOO",
+		"\n1FOO=bar":           `dotenv line 2: invalid variable name "1FOO"`,
+		"A=1\nB=\"open\nC=2\n": "dotenv line 2: unterminated quoted value for B",
+		"A='x' y":              "dotenv line 1: unexpected characters after quoted value for A",
+		"A=\"x\ny\" z":         "dotenv line 2: unexpected characters after quoted value for A",
+	}
+	for input, exp := range errors {
+		_, err := parseEnvOutput("dotenv", []byte(input))
+		if assert.NotNil(t, err, input) {
+			assert.Equal(t, exp, err.Error(), input)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package config
+
+import (
+	"os/exec"
+)
+
+// detachTerminal does nothing on systems without sessions.
+func detachTerminal(cmd *exec.Cmd) {}
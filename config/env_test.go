@@ -2,11 +2,21 @@ package config
 
 import (
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
 )
 
+func intPtr(i int) *int {
+	return &i
+}
+
 func envIsUnset(key string) bool {
 	_, ok := os.LookupEnv(key)
 	return !ok
@@ -79,6 +89,225 @@ func TestConfigEnv(t *testing.T) {
 		assert.Equal(t, os.Getenv("MUSS_TEST_ENV"), "42")
 	})
 
+	t.Run("parse false", func(t *testing.T) {
+		os.Unsetenv("MUSS_TEST_ENV")
+		defer os.Unsetenv("MUSS_TEST_ENV")
+
+		cfg := newTestConfig(t, nil)
+		cfg.Secrets = append(cfg.Secrets, &EnvCommand{
+			Parse:   false,
+			Varname: "MUSS_TEST_ENV",
+			Exec:    []string{"echo", "A=1"},
+		})
+
+		assert.Nil(t, cfg.LoadEnv(), "no errors")
+		assert.Equal(t, "A=1", os.Getenv("MUSS_TEST_ENV"), "not parsed")
+	})
+
+	t.Run("structured output", func(t *testing.T) {
+		vars := []string{"MUSS_TEST_JSON_USER", "MUSS_TEST_JSON_PASS", "MUSS_TEST_FIELD", "MUSS_TEST_DOTENV", "user", "port"}
+		for _, v := range vars {
+			os.Unsetenv(v)
+			defer os.Unsetenv(v)
+		}
+
+		json := `{"user": "bob", "port": 5432, "data": {"password": "pw"}}`
+
+		err := loadEnvFromCmds(
+			&EnvCommand{
+				Exec:  []string{"echo", json},
+				Parse: "json",
+				Fields: map[string]string{
+					"user":          "MUSS_TEST_JSON_USER",
+					"data.password": "MUSS_TEST_JSON_PASS",
+				},
+			},
+			&EnvCommand{
+				Exec:    []string{"echo", "data:\n  token: t0k3n\n"},
+				Parse:   "yaml",
+				Field:   "data.token",
+				Varname: "MUSS_TEST_FIELD",
+			},
+			&EnvCommand{
+				Exec:  []string{"echo", `export MUSS_TEST_DOTENV="a \"b\"" # c`},
+				Parse: "dotenv",
+			},
+		)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "bob", os.Getenv("MUSS_TEST_JSON_USER"))
+		assert.Equal(t, "pw", os.Getenv("MUSS_TEST_JSON_PASS"))
+		assert.True(t, envIsUnset("user"), "only mapped fields are set")
+		assert.Equal(t, "t0k3n", os.Getenv("MUSS_TEST_FIELD"))
+		assert.Equal(t, `a "b"`, os.Getenv("MUSS_TEST_DOTENV"))
+
+		err = loadEnvFromCmds(&EnvCommand{
+			Exec:  []string{"echo", `{"user": "bob", "port": 5432}`},
+			Parse: "json",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "bob", os.Getenv("user"), "top level keys")
+		assert.Equal(t, "5432", os.Getenv("port"), "top level keys")
+
+		errors := map[string]*EnvCommand{
+			`invalid value for "parse": toml (must be true, "json", "yaml", or "dotenv")`: &EnvCommand{
+				Exec: []string{"true"}, Parse: "toml",
+			},
+			`"field" and "fields" require "parse"`: &EnvCommand{
+				Exec: []string{"true"}, Varname: "MUSS_TEST_X", Field: "x",
+			},
+			`use "field" or "fields", not both`: &EnvCommand{
+				Exec: []string{"true"}, Parse: "json", Field: "x", Fields: map[string]string{"y": "Y"},
+			},
+			`"field" requires a "varname"`: &EnvCommand{
+				Exec: []string{"true"}, Parse: "json", Field: "x",
+			},
+			`field "nope" not found in output`: &EnvCommand{
+				Exec: []string{"echo", "{}"}, Parse: "json", Fields: map[string]string{"nope": "MUSS_TEST_X"},
+			},
+			`failed to parse json output: invalid character 'o' in literal null (expecting 'u')`: &EnvCommand{
+				Exec: []string{"echo", "not json"}, Parse: "json",
+			},
+		}
+		for exp, envCmd := range errors {
+			err := loadEnvFromCmds(envCmd)
+			if assert.NotNil(t, err, exp) {
+				assert.Equal(t, exp, err.Error())
+			}
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		pidFile := testutil.TempFile(t, "", "muss-timeout")
+		pidFile.Close()
+		defer os.Remove(pidFile.Name())
+
+		start := time.Now()
+		_, err := (&EnvCommand{
+			Exec:    []string{"/bin/sh", "-c", `sleep 2 & echo $! > "$0"; wait`, pidFile.Name()},
+			Timeout: 200 * time.Millisecond,
+		}).Value()
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "command timed out after 200ms", err.Error())
+		}
+		assert.True(t, time.Since(start) < 2*time.Second, "did not wait for children")
+
+		pid, err := strconv.Atoi(strings.TrimSpace(testutil.ReadFile(t, pidFile.Name())))
+		if err != nil {
+			t.Fatalf("failed to read pid: %s", err)
+		}
+		// Give the kill a moment (and count zombies as dead
+		// since it's up to init to reap them).
+		time.Sleep(100 * time.Millisecond)
+		state, _ := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+		assert.Regexp(t, `^(Z.*)?$`, strings.TrimSpace(string(state)), "children were killed")
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		count := testutil.TempFile(t, "", "muss-retries")
+		count.Close()
+		defer os.Remove(count.Name())
+
+		// Fail until the third attempt.
+		script := []string{"/bin/sh", "-c", `echo x >> "$0"; [ $(wc -l < "$0") -ge 3 ] && echo done`, count.Name()}
+
+		_, err := (&EnvCommand{Exec: script, Retries: intPtr(1)}).Value()
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "command failed: exit status 1", err.Error())
+		}
+
+		out, err := (&EnvCommand{Exec: script, Retries: intPtr(1)}).Value()
+		assert.Nil(t, err)
+		assert.Equal(t, "done", string(out))
+		assert.Equal(t, "x\nx\nx\n", testutil.ReadFile(t, count.Name()))
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		e := &EnvCommand{Retries: intPtr(2)}
+		d := e.withDefaults(time.Second, 5)
+
+		assert.Equal(t, time.Second, d.Timeout, "default")
+		assert.Equal(t, 2, *d.Retries, "command overrides default")
+
+		d = (&EnvCommand{Retries: intPtr(0)}).withDefaults(time.Second, 5)
+		assert.Equal(t, 0, *d.Retries, "0 overrides default")
+
+		d = (&EnvCommand{}).withDefaults(time.Second, 5)
+		assert.Equal(t, 5, *d.Retries, "unset uses default")
+		assert.Equal(t, time.Duration(0), e.Timeout, "original unchanged")
+	})
+
+	t.Run("non-interactive", func(t *testing.T) {
+		os.Setenv("MUSS_NONINTERACTIVE", "1")
+		defer os.Unsetenv("MUSS_NONINTERACTIVE")
+
+		out, err := (&EnvCommand{
+			Exec: []string{"/bin/sh", "-c", "cat; echo done"},
+		}).Value()
+		assert.Nil(t, err)
+		assert.Equal(t, "done", string(out), "stdin is empty")
+
+		_, err = (&EnvCommand{
+			Exec:    []string{"/bin/sh", "-c", "read x < /dev/tty"},
+			Varname: "MUSS_TEST_PROMPTED",
+		}).Value()
+		if assert.NotNil(t, err) {
+			assert.Regexp(t, `^MUSS_TEST_PROMPTED failed in non-interactive mode \(it may need input\): command failed: exit status \d+$`, err.Error())
+		}
+
+		count := testutil.TempFile(t, "", "muss-input-retries")
+		count.Close()
+		defer os.Remove(count.Name())
+
+		_, err = (&EnvCommand{
+			Exec:    []string{"/bin/sh", "-c", `echo x >> "$0"; read x || { echo 'error reading stdin: unexpected EOF' >&2; exit 1; }`, count.Name()},
+			Parse:   true,
+			Retries: intPtr(2),
+		}).Value()
+		if assert.NotNil(t, err) {
+			assert.Regexp(t, `failed in non-interactive mode \(it may need input\): command failed: exit status 1$`, err.Error())
+		}
+		assert.Equal(t, "x\n", testutil.ReadFile(t, count.Name()), "not retried")
+
+		for _, message := range []string{
+			"gpg: cannot open '/dev/tty': No such device or address",
+			"sudo: a terminal is required to read the password",
+			"stty: 'standard input': Inappropriate ioctl for device",
+			"Error: stdin is not a tty",
+			"read: end of file on standard input",
+		} {
+			assert.True(t, reNeedsInput.MatchString(message), "input error: %s", message)
+		}
+
+		for _, message := range []string{
+			"access denied",
+			"invalid input",
+			"unexpected EOF",
+			"stdin: ok",
+			"terminal velocity",
+		} {
+			_, err = (&EnvCommand{
+				Exec:  []string{"/bin/sh", "-c", `echo "$0" >&2; exit 1`, message},
+				Parse: true,
+			}).Value()
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "command failed: exit status 1", err.Error(), "not an input error: %s", message)
+			}
+		}
+
+		_, err = (&EnvCommand{
+			Exec:    []string{"sleep", "2"},
+			Timeout: 100 * time.Millisecond,
+		}).Value()
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "command timed out after 100ms", err.Error(), "not an input error")
+		}
+
+		os.Setenv("MUSS_NONINTERACTIVE", "0")
+		assert.False(t, nonInteractive(), "0 is false")
+	})
+
 	t.Run("returns error", func(t *testing.T) {
 		cfg := newTestConfig(t, nil)
 
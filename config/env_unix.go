@@ -0,0 +1,14 @@
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package config
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachTerminal starts the command in a new session so that it has no
+// controlling terminal (opening /dev/tty will fail).
+func detachTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// Formats for generated secrets.
+const (
+	generateHex    = "hex"
+	generateBase64 = "base64"
+	generateUUID   = "uuid"
+)
+
+const (
+	defaultGenerateLength  = 32
+	defaultGenerateCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// secretGenerator creates random values for generated secrets.
+type secretGenerator struct {
+	length  int
+	format  string
+	charset string
+}
+
+// parseGenerator accepts a map of options (or nothing for the defaults).
+func parseGenerator(v interface{}) (*secretGenerator, error) {
+	g := &secretGenerator{}
+
+	var m map[string]interface{}
+	switch val := v.(type) {
+	case nil:
+	case bool:
+		if !val {
+			return nil, fmt.Errorf("value for secret generate must be a map of options")
+		}
+	case map[string]interface{}:
+		m = val
+	default:
+		return nil, fmt.Errorf("value for secret generate must be a map of options")
+	}
+
+	for k, val := range m {
+		switch k {
+		case "length":
+			length, ok := val.(int)
+			if !ok || length <= 0 {
+				return nil, fmt.Errorf("value for generate length must be a positive integer")
+			}
+			g.length = length
+		case "format":
+			format, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("value for generate format must be a string")
+			}
+			switch format {
+			case generateHex, generateBase64, generateUUID:
+			default:
+				return nil, fmt.Errorf(`invalid generate format %q (must be "hex", "base64", or "uuid")`, format)
+			}
+			g.format = format
+		case "charset":
+			charset, ok := val.(string)
+			if !ok || charset == "" {
+				return nil, fmt.Errorf("value for generate charset must be a non-empty string")
+			}
+			g.charset = charset
+		default:
+			return nil, fmt.Errorf("unknown generate option %q", k)
+		}
+	}
+
+	if g.format != "" && g.charset != "" {
+		return nil, fmt.Errorf(`use generate "format" or "charset", not both`)
+	}
+	if g.format == generateUUID && g.length != 0 {
+		return nil, fmt.Errorf(`generate "length" cannot be used with "uuid"`)
+	}
+	if g.length == 0 {
+		g.length = defaultGenerateLength
+	}
+	if g.format == "" && g.charset == "" {
+		g.charset = defaultGenerateCharset
+	}
+
+	return g, nil
+}
+
+// Value returns a new random value.
+func (g *secretGenerator) Value() ([]byte, error) {
+	switch g.format {
+	case generateHex:
+		b, err := randomBytes((g.length + 1) / 2)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(hex.EncodeToString(b)[:g.length]), nil
+	case generateBase64:
+		b, err := randomBytes(g.length*3/4 + 3)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(base64.RawURLEncoding.EncodeToString(b)[:g.length]), nil
+	case generateUUID:
+		b, err := randomBytes(16)
+		if err != nil {
+			return nil, err
+		}
+		// Version 4 (random), variant 10.
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return []byte(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])), nil
+	}
+
+	charset := []rune(g.charset)
+	max := big.NewInt(int64(len(charset)))
+	value := make([]rune, g.length)
+	for i := range value {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %s", err)
+		}
+		value[i] = charset[n.Int64()]
+	}
+	return []byte(string(value)), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %s", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretGenerator(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		g, err := parseGenerator(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, &secretGenerator{length: 32, charset: defaultGenerateCharset}, g, "defaults")
+
+		g, err = parseGenerator(map[string]interface{}{"format": "hex", "length": 12})
+		assert.Nil(t, err)
+		assert.Equal(t, &secretGenerator{length: 12, format: "hex"}, g)
+
+		errors := map[string]interface{}{
+			"value for secret generate must be a map of options":                   "hex",
+			"value for generate length must be a positive integer":                 map[string]interface{}{"length": 0},
+			"value for generate format must be a string":                           map[string]interface{}{"format": 1},
+			`invalid generate format "words" (must be "hex", "base64", or "uuid")`: map[string]interface{}{"format": "words"},
+			"value for generate charset must be a non-empty string":                map[string]interface{}{"charset": ""},
+			`use generate "format" or "charset", not both`:                         map[string]interface{}{"format": "hex", "charset": "ab"},
+			`generate "length" cannot be used with "uuid"`:                         map[string]interface{}{"format": "uuid", "length": 4},
+			`unknown generate option "size"`:                                       map[string]interface{}{"size": 4},
+		}
+		for exp, spec := range errors {
+			_, err := parseGenerator(spec)
+			if assert.NotNil(t, err, exp) {
+				assert.Equal(t, exp, err.Error())
+			}
+		}
+	})
+
+	t.Run("values", func(t *testing.T) {
+		formats := map[string]*secretGenerator{
+			`^[0-9a-f]{7}$`:       &secretGenerator{length: 7, format: "hex"},
+			`^[A-Za-z0-9_-]{22}$`: &secretGenerator{length: 22, format: "base64"},
+			`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`: &secretGenerator{format: "uuid"},
+			`^[xyé]{50}$`: &secretGenerator{length: 50, charset: "xyé"},
+		}
+		for pattern, g := range formats {
+			value, err := g.Value()
+			assert.Nil(t, err)
+			assert.Regexp(t, pattern, string(value))
+
+			again, _ := g.Value()
+			assert.NotEqual(t, value, again, "random")
+		}
+	})
+}
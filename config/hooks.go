@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// What to do when a hook fails.
+const (
+	// HookFail stops running hooks (and the command, for "pre" hooks)
+	// and makes muss exit non-zero.
+	HookFail = "fail"
+	// HookWarn prints a warning and continues.
+	HookWarn = "warn"
+	// HookIgnore continues silently.
+	HookIgnore = "ignore"
+)
+
+// execCommands replace the muss process so they can't have "post" hooks
+// ("wrap" only does with --exec so it warns when it runs).
+var execCommands = map[string]bool{
+	"dc":   true,
+	"exec": true,
+	"run":  true,
+}
+
+// Hook is a command to run before or after a muss command.
+type Hook struct {
+	// Exec is the command (and args) to run.
+	Exec []string `yaml:"exec"`
+	// Service (if set) runs the command in the (running) container
+	// of that service rather than on the host.
+	Service string `yaml:"service,omitempty"`
+	// OnFailure is one of "fail" (the default), "warn" or "ignore".
+	OnFailure string `yaml:"on_failure,omitempty"`
+
+	// Source names the service definition that defined the hook
+	// (or is empty for the project config).
+	Source string `yaml:"-"`
+}
+
+// Hooks maps hook names ("pre_up", "post_down", etc) to the hooks to run.
+type Hooks map[string][]*Hook
+
+// FailureMode returns what to do when the hook fails.
+func (h *Hook) FailureMode() string {
+	if h.OnFailure == "" {
+		return HookFail
+	}
+	return h.OnFailure
+}
+
+// String describes the hook for messages.
+func (h *Hook) String() string {
+	desc := strings.Join(h.Exec, " ")
+	if h.Service != "" {
+		desc = fmt.Sprintf("%s (in %s)", desc, h.Service)
+	}
+	return desc
+}
+
+func (hooks Hooks) validate() error {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !(strings.HasPrefix(name, "pre_") || strings.HasPrefix(name, "post_")) || strings.HasSuffix(name, "_") {
+			return fmt.Errorf("invalid hook name '%s'; must be pre_<command> or post_<command>", name)
+		}
+		if command := strings.TrimPrefix(name, "post_"); command != name && execCommands[command] {
+			return fmt.Errorf("invalid hook name '%s'; '%s' replaces the muss process so it cannot run post hooks", name, command)
+		}
+		for _, hook := range hooks[name] {
+			if hook == nil || len(hook.Exec) == 0 {
+				return fmt.Errorf("hook %s must have exec", name)
+			}
+			switch hook.FailureMode() {
+			case HookFail, HookWarn, HookIgnore:
+			default:
+				return fmt.Errorf("invalid on_failure '%s' for hook %s; must be fail, warn, or ignore", hook.OnFailure, name)
+			}
+		}
+	}
+	return nil
+}
+
+// parseHooks reads the hooks from a service definition config.
+func parseHooks(source string, spec interface{}) (Hooks, error) {
+	var hooks Hooks
+	if err := mapToStruct(spec, &hooks); err != nil {
+		return nil, fmt.Errorf("invalid hooks for %s: %w", source, err)
+	}
+	if err := hooks.validate(); err != nil {
+		return nil, fmt.Errorf("invalid hooks for %s: %w", source, err)
+	}
+	for _, list := range hooks {
+		for _, hook := range list {
+			hook.Source = source
+		}
+	}
+	return hooks, nil
+}
+
+// HooksFor returns the hooks to run for the name ("pre_up", etc):
+// those from the project config followed by those from the chosen config of
+// each service definition (in order).
+func (cfg *ProjectConfig) HooksFor(name string) ([]*Hook, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	// Only load the compose config (to choose the service definition configs)
+	// if they have hooks.
+	if cfg.serviceDefinitionsHaveHooks() {
+		if err := cfg.loadComposeConfig(); err != nil {
+			return nil, err
+		}
+	}
+	hooks := append([]*Hook{}, cfg.Hooks[name]...)
+	for _, serviceHooks := range cfg.serviceHooks {
+		hooks = append(hooks, serviceHooks[name]...)
+	}
+	return hooks, nil
+}
+
+func (cfg *ProjectConfig) serviceDefinitionsHaveHooks() bool {
+	for _, def := range cfg.ServiceDefinitions {
+		if def.hasHooks() {
+			return true
+		}
+	}
+	return false
+}
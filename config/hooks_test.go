@@ -0,0 +1,209 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hookExecs(t *testing.T, cfg *ProjectConfig, name string) [][]string {
+	t.Helper()
+	hooks, err := cfg.HooksFor(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	execs := make([][]string, len(hooks))
+	for i, hook := range hooks {
+		execs[i] = hook.Exec
+	}
+	return execs
+}
+
+func TestHooks(t *testing.T) {
+	serviceDefs := `
+default_service_preference: [repo, registry]
+hooks:
+  pre_up:
+    - exec: [project, pre]
+  post_down:
+    - exec: [project, post]
+      on_failure: warn
+service_definitions:
+- name: app
+  configs:
+    repo:
+      hooks:
+        pre_up:
+          - exec: [migrate]
+            service: app
+    registry:
+      hooks:
+        pre_up:
+          - exec: [pull-assets]
+- name: db
+  configs:
+    sole:
+      hooks:
+        pre_up:
+          - exec: [wait-for-db]
+            on_failure: ignore
+`
+
+	t.Run("project and chosen service configs", func(t *testing.T) {
+		_, cfg, err := parseAndCompose(serviceDefs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		assert.Equal(t,
+			[][]string{{"project", "pre"}, {"migrate"}, {"wait-for-db"}},
+			hookExecs(t, cfg, "pre_up"),
+		)
+		assert.Equal(t, [][]string{{"project", "post"}}, hookExecs(t, cfg, "post_down"))
+		assert.Equal(t, [][]string{}, hookExecs(t, cfg, "pre_down"))
+
+		hooks, _ := cfg.HooksFor("pre_up")
+		assert.Equal(t, "", hooks[0].Source)
+		assert.Equal(t, HookFail, hooks[0].FailureMode())
+		assert.Equal(t, "app", hooks[1].Source)
+		assert.Equal(t, "migrate (in app)", hooks[1].String())
+		assert.Equal(t, HookIgnore, hooks[2].FailureMode())
+	})
+
+	t.Run("other config chosen", func(t *testing.T) {
+		_, cfg, err := parseAndCompose(serviceDefs + `
+user: {service_preference: [registry]}
+`)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		assert.Equal(t,
+			[][]string{{"project", "pre"}, {"pull-assets"}, {"wait-for-db"}},
+			hookExecs(t, cfg, "pre_up"),
+		)
+	})
+
+	t.Run("disabled service", func(t *testing.T) {
+		_, cfg, err := parseAndCompose(serviceDefs + `
+user: {services: {app: {disabled: true}}}
+`)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		assert.Equal(t,
+			[][]string{{"project", "pre"}, {"wait-for-db"}},
+			hookExecs(t, cfg, "pre_up"),
+		)
+	})
+
+	t.Run("hooks are not compose config", func(t *testing.T) {
+		assertComposed(t, serviceDefs, `{version: '3.7'}`, "hooks removed from config")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assertConfigError(t, `
+hooks:
+  up:
+    - exec: [echo]
+`,
+			"invalid hook name 'up'; must be pre_<command> or post_<command>",
+			"bad name")
+
+		assertConfigError(t, `
+hooks:
+  pre_:
+    - exec: [echo]
+`,
+			"invalid hook name 'pre_'",
+			"missing command")
+
+		assertConfigError(t, `
+hooks:
+  pre_up:
+    - service: app
+`,
+			"hook pre_up must have exec",
+			"missing exec")
+
+		assertConfigError(t, `
+hooks:
+  post_up:
+    - exec: [echo]
+      on_failure: explode
+`,
+			"invalid on_failure 'explode' for hook post_up; must be fail, warn, or ignore",
+			"bad on_failure")
+
+		assertConfigError(t, `
+service_definitions:
+- name: app
+  configs:
+    sole:
+      hooks:
+        pre_build:
+          - exec: []
+`,
+			"invalid hooks for app: hook pre_build must have exec",
+			"service definition hook")
+
+		assertConfigError(t, `
+hooks:
+  post_run:
+    - exec: [echo]
+`,
+			"invalid hook name 'post_run'; 'run' replaces the muss process so it cannot run post hooks",
+			"post hook for exec command")
+
+		assertConfigError(t, `
+service_definitions:
+- name: app
+  configs:
+    sole:
+      hooks:
+        post_exec:
+          - exec: [echo]
+`,
+			"invalid hooks for app: invalid hook name 'post_exec'",
+			"service definition post hook for exec command")
+	})
+
+	t.Run("post_wrap", func(t *testing.T) {
+		_, cfg, err := parseAndCompose(`
+hooks:
+  post_wrap:
+    - exec: [echo]
+`)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Equal(t, [][]string{{"echo"}}, hookExecs(t, cfg, "post_wrap"), "wrap only execs with --exec")
+	})
+
+	t.Run("service definitions without hooks", func(t *testing.T) {
+		parsed, err := parseYaml([]byte(`
+hooks:
+  pre_up:
+    - exec: [project, pre]
+service_definitions:
+- name: app
+  configs:
+    sole:
+      services: {app: {image: app}}
+user: {services: {app: {config: missing}}}
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := NewConfigFromMap(parsed)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// The compose config (with its bad choice) isn't loaded.
+		assert.Equal(t, [][]string{{"project", "pre"}}, hookExecs(t, cfg, "pre_up"))
+		_, err = cfg.ComposeConfig()
+		assert.NotNil(t, err)
+	})
+}
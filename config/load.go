@@ -7,6 +7,8 @@ import (
 
 	"github.com/mitchellh/mapstructure"
 	yaml "gopkg.in/yaml.v2"
+
+	"gerrit.instructure.com/muss/backend"
 )
 
 var defaultProjectFile = "muss.yaml"
@@ -45,6 +47,23 @@ func (cfg *ProjectConfig) loadMap(object map[string]interface{}) error {
 	}
 	cfg.ServiceDefinitions = append(cfg.ServiceDefinitions, loaded...)
 
+	if err := cfg.Hooks.validate(); err != nil {
+		return err
+	}
+	if err := cfg.validateCommands(); err != nil {
+		return err
+	}
+
+	// Prefer env backend if present.
+	if envBackend := os.Getenv(backend.EnvVar); envBackend != "" {
+		cfg.Backend = envBackend
+	}
+	if cfg.Backend != "" {
+		if _, err := backend.Lookup(cfg.Backend); err != nil {
+			return err
+		}
+	}
+
 	// Prefer env user file if present.
 	if envUserFile := os.Getenv("MUSS_USER_FILE"); envUserFile != "" {
 		cfg.UserFile = envUserFile
@@ -172,27 +191,26 @@ func parseYaml(content []byte) (map[string]interface{}, error) {
 func stringifyKeys(m map[interface{}]interface{}) map[string]interface{} {
 	result := make(map[string]interface{}, len(m))
 	for k, v := range m {
-		key := k.(string)
-
-		if vm, ok := v.(map[interface{}]interface{}); ok {
-			result[key] = stringifyKeys(vm)
-
-		} else if vs, ok := v.([]interface{}); ok {
-			slice := make([]interface{}, len(vs))
-			for i, item := range vs {
-				if m, ok := item.(map[interface{}]interface{}); ok {
-					slice[i] = stringifyKeys(m)
-				} else {
-					slice[i] = item
-				}
-			}
-			result[key] = slice
+		result[k.(string)] = stringifyValue(v)
+	}
+	return result
+}
 
-		} else {
-			result[key] = v
+// stringifyValue converts any maps within the value (including those nested
+// in slices) to have string keys.
+func stringifyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		return stringifyKeys(value)
+	case []interface{}:
+		slice := make([]interface{}, len(value))
+		for i, item := range value {
+			slice[i] = stringifyValue(item)
 		}
+		return slice
+	default:
+		return v
 	}
-	return result
 }
 
 func stringSlice(obj interface{}) ([]string, bool) {
@@ -207,3 +225,20 @@ func stringSlice(obj interface{}) ([]string, bool) {
 	}
 	return nil, false
 }
+
+func stringMap(obj interface{}) (map[string]string, bool) {
+	if m, ok := obj.(map[string]string); ok {
+		return m, true
+	} else if m, ok := obj.(map[string]interface{}); ok {
+		strings := make(map[string]string, len(m))
+		for k, v := range m {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			strings[k] = s
+		}
+		return strings, true
+	}
+	return nil, false
+}
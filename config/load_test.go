@@ -86,6 +86,23 @@ func TestConfigLoad(t *testing.T) {
 			assert.Equal(t, "1.3", cfg.User.Override["version"].(string))
 		})
 
+		t.Run("backend", func(t *testing.T) {
+			defer os.Unsetenv("MUSS_BACKEND")
+
+			cfg, err := NewConfigFromMap(map[string]interface{}{"backend": "podman"})
+			assert.Nil(t, err)
+			assert.Equal(t, "podman", cfg.Backend)
+
+			os.Setenv("MUSS_BACKEND", "docker")
+			cfg, err = NewConfigFromMap(map[string]interface{}{"backend": "podman"})
+			assert.Nil(t, err)
+			assert.Equal(t, "docker", cfg.Backend, "env overrides project")
+
+			os.Setenv("MUSS_BACKEND", "dockre")
+			_, err = NewConfigFromMap(nil)
+			assert.Equal(t, "unknown backend 'dockre' (expected one of docker, docker-compose, podman, podman-compose)", err.Error())
+		})
+
 		t.Run("readCachedYamlFile", func(t *testing.T) {
 			file := "cached.yml"
 			testutil.NoFileExists(t, file)
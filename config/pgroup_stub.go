@@ -0,0 +1,26 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package config
+
+import (
+	"os"
+	"os/exec"
+)
+
+// startProcessGroup just starts the command on systems without process groups
+// and returns a channel that gets the result of waiting for it.
+func startProcessGroup(cmd *exec.Cmd, stdin *os.File) (<-chan error, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+	return waitCh, nil
+}
+
+// killProcessGroup kills just the process on systems without process groups.
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
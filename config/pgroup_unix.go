@@ -0,0 +1,151 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// startProcessGroup starts the command in its own (background) process group
+// so that killProcessGroup can stop anything it starts
+// and returns a channel that gets the result of waiting for it.
+// Commands run in parallel so none of them has the terminal (stdin) until it
+// tries to use it: then it is stopped (like a background job in a shell)
+// and waits for its turn to be put in the foreground and continued.
+func startProcessGroup(cmd *exec.Cmd, stdin *os.File) (<-chan error, error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	// We wait for the process ourselves (to see when it stops)
+	// so cmd.Wait can't be used to finish copying the output.
+	var copied chan error
+	if stdout := cmd.Stdout; stdout != nil {
+		if _, ok := stdout.(*os.File); !ok {
+			r, w, err := os.Pipe()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Stdout = w
+			defer w.Close()
+			copied = make(chan error, 1)
+			go func() {
+				_, err := io.Copy(stdout, r)
+				r.Close()
+				copied <- err
+			}()
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	waitCh := make(chan error, 1)
+	go func() {
+		err := waitProcessGroup(cmd.Process.Pid, int(stdin.Fd()))
+		if copied != nil {
+			if cerr := <-copied; err == nil {
+				err = cerr
+			}
+		}
+		waitCh <- err
+	}()
+	return waitCh, nil
+}
+
+// waitProcessGroup waits for the process to exit,
+// giving it the terminal if it stops to use it.
+func waitProcessGroup(pid, fd int) error {
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	for {
+		var status syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &status, syscall.WUNTRACED, nil); err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return err
+		}
+
+		if !status.Stopped() {
+			return exitError(status)
+		}
+
+		// It wants the terminal.
+		if release == nil {
+			if release = takeTerminal(fd, pid); release == nil {
+				// Muss doesn't have the terminal to give
+				// so it stays stopped (as muss would).
+				continue
+			}
+		}
+		syscall.Kill(-pid, syscall.SIGCONT)
+	}
+}
+
+// exitError describes an unsuccessful exit like exec.ExitError.
+func exitError(status syscall.WaitStatus) error {
+	switch {
+	case status.Exited() && status.ExitStatus() == 0:
+		return nil
+	case status.Signaled():
+		return fmt.Errorf("signal: %s", status.Signal())
+	default:
+		return fmt.Errorf("exit status %d", status.ExitStatus())
+	}
+}
+
+// takeTerminal waits for its turn and then puts the process group in the
+// foreground of the terminal (if muss has it) until release is called.
+// It can be replaced in tests (which don't have a terminal).
+var takeTerminal = func(fd, pgid int) (release func()) {
+	terminalMutex.Lock()
+	if !isForeground(fd) {
+		terminalMutex.Unlock()
+		return nil
+	}
+	setForeground(fd, pgid)
+	return func() {
+		setForeground(fd, syscall.Getpgrp())
+		terminalMutex.Unlock()
+	}
+}
+
+// killProcessGroup kills the process and any others in its group.
+func killProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
+
+// isForeground returns true if the fd is a terminal
+// and the process group of muss is in its foreground.
+func isForeground(fd int) bool {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return false
+	}
+	return int(pgrp) == syscall.Getpgrp()
+}
+
+// setForeground puts the process group in the foreground of the terminal.
+func setForeground(fd, pgid int) {
+	// Changing the foreground from the background sends SIGTTOU
+	// (which would stop muss).
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	pgrp := int32(pgid)
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgrp)))
+}
@@ -0,0 +1,73 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package config
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessGroupTerminal(t *testing.T) {
+	orig := takeTerminal
+	defer func() { takeTerminal = orig }()
+
+	var mutex sync.Mutex
+	taken, released := 0, 0
+	takeTerminal = func(fd, pgid int) func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		taken++
+		return func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			released++
+		}
+	}
+
+	t.Run("only when stopped", func(t *testing.T) {
+		taken, released = 0, 0
+		defer os.Unsetenv("MUSS_TEST_PGROUP_ONE")
+		defer os.Unsetenv("MUSS_TEST_PGROUP_TWO")
+
+		// Loaders that don't use the terminal run in parallel.
+		start := time.Now()
+		err := loadEnvFromCmds(
+			&EnvCommand{Exec: []string{"sleep", "0.3"}, Varname: "MUSS_TEST_PGROUP_ONE"},
+			&EnvCommand{Exec: []string{"sleep", "0.3"}, Varname: "MUSS_TEST_PGROUP_TWO"},
+		)
+		assert.Nil(t, err)
+		assert.True(t, time.Since(start) < 550*time.Millisecond, "in parallel")
+		assert.Equal(t, 0, taken)
+	})
+
+	t.Run("stopped command continues with the terminal", func(t *testing.T) {
+		taken, released = 0, 0
+
+		// Stop like a background job reading the terminal.
+		out, err := (&EnvCommand{
+			Exec:    []string{"/bin/sh", "-c", "kill -TTIN $$; echo resumed"},
+			Timeout: 2 * time.Second,
+		}).Value()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "resumed", string(out))
+		assert.Equal(t, 1, taken)
+		assert.Equal(t, 1, released, "terminal given back")
+	})
+
+	t.Run("exit status", func(t *testing.T) {
+		_, err := (&EnvCommand{Exec: []string{"/bin/sh", "-c", "exit 3"}}).Value()
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "command failed: exit status 3", err.Error())
+		}
+
+		_, err = (&EnvCommand{Exec: []string{"/bin/sh", "-c", "kill -TERM $$"}}).Value()
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "command failed: signal: terminated", err.Error())
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // ProjectConfig is a type for the parsed contents of the project config file.
@@ -16,6 +18,12 @@ type ProjectConfig struct {
 	Status                   *StatusConfig             `yaml:"status"`
 	ProjectName              string                    `yaml:"project_name"`
 	ComposeFile              string                    `yaml:"compose_file"`
+	CommandTimeout           time.Duration             `yaml:"command_timeout,omitempty"`
+	CommandRetries           int                       `yaml:"command_retries,omitempty"`
+	AuditLog                 bool                      `yaml:"audit_log,omitempty"`
+	Backend                  string                    `yaml:"backend,omitempty"`
+	Hooks                    Hooks                     `yaml:"hooks,omitempty"`
+	Commands                 map[string]*Command       `yaml:"commands,omitempty"`
 
 	Secrets     []envLoader `yaml:"-"`
 	ProjectFile string      `yaml:"-"`
@@ -24,6 +32,9 @@ type ProjectConfig struct {
 
 	composeConfig   map[string]interface{}
 	filesToGenerate FileGenMap
+	serviceHooks    []Hooks
+	// definitionServices are the services each service definition runs.
+	definitionServices map[string][]string
 }
 
 func newProjectConfig() *ProjectConfig {
@@ -52,3 +63,12 @@ func (cfg *ProjectConfig) ToMap() (map[string]interface{}, error) {
 	}
 	return cfgMap, nil
 }
+
+// ToJSON returns the project config as JSON (for plugins).
+func (cfg *ProjectConfig) ToJSON() ([]byte, error) {
+	cfgMap, err := cfg.ToMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cfgMap)
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// How many times to ask before giving up on invalid input.
+const promptAttempts = 3
+
+// secretPrompt reads a secret value from the user.
+type secretPrompt struct {
+	text     string
+	validate *regexp.Regexp
+	confirm  bool
+}
+
+// Only one prompt (or command that stopped to use the terminal) at a time
+// can have the terminal (secrets are loaded in parallel).
+var terminalMutex sync.Mutex
+
+// readPassword reads a line from the terminal without echoing it.
+var readPassword = func(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open terminal: %s", err)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	// The newline isn't echoed either.
+	defer fmt.Fprintln(tty)
+
+	return terminal.ReadPassword(int(tty.Fd()))
+}
+
+// parsePrompt accepts either the prompt text or a map of options.
+func parsePrompt(v interface{}) (*secretPrompt, error) {
+	if text, ok := v.(string); ok {
+		return &secretPrompt{text: text}, nil
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value for secret prompt must be a string or a map")
+	}
+
+	p := &secretPrompt{}
+	for k, val := range m {
+		switch k {
+		case "text":
+			if p.text, ok = val.(string); !ok {
+				return nil, fmt.Errorf("value for prompt text must be a string")
+			}
+		case "validate":
+			pattern, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("value for prompt validate must be a string")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prompt validate pattern: %s", err)
+			}
+			p.validate = re
+		case "confirm":
+			if p.confirm, ok = val.(bool); !ok {
+				return nil, fmt.Errorf("value for prompt confirm must be a boolean")
+			}
+		default:
+			return nil, fmt.Errorf("unknown prompt option %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Value prompts for the secret (and confirmation, if configured)
+// until valid input is received.
+func (p *secretPrompt) Value(varname string) ([]byte, error) {
+	if nonInteractive() {
+		return nil, fmt.Errorf("cannot prompt for %s in non-interactive mode", varname)
+	}
+
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+
+	text := p.text
+	if text == "" {
+		text = varname
+	}
+
+	var problem string
+	for i := 0; i < promptAttempts; i++ {
+		if problem != "" {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+
+		value, err := readPassword(text + ": ")
+		if err != nil {
+			return nil, err
+		}
+
+		if len(value) == 0 {
+			problem = "a value is required"
+			continue
+		}
+		if p.validate != nil && !p.validate.Match(value) {
+			problem = fmt.Sprintf("value does not match %s", p.validate)
+			continue
+		}
+		if p.confirm {
+			again, err := readPassword("Confirm " + text + ": ")
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(value, again) {
+				problem = "values do not match"
+				continue
+			}
+		}
+
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("no valid value entered for %s (%s)", varname, problem)
+}
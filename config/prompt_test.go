@@ -0,0 +1,190 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+// withInput replaces the terminal with the provided lines of input
+// while calling f and returns the prompts that were shown.
+func withInput(input []string, f func()) []string {
+	orig := readPassword
+	defer func() { readPassword = orig }()
+
+	prompts := make([]string, 0)
+	readPassword = func(prompt string) ([]byte, error) {
+		prompts = append(prompts, prompt)
+		if len(input) == 0 {
+			return nil, errors.New("EOF")
+		}
+		line := input[0]
+		input = input[1:]
+		return []byte(line), nil
+	}
+
+	f()
+
+	return prompts
+}
+
+func TestSecretPrompt(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		p, err := parsePrompt("API token")
+		assert.Nil(t, err)
+		assert.Equal(t, &secretPrompt{text: "API token"}, p)
+
+		p, err = parsePrompt(map[string]interface{}{
+			"text":     "API token",
+			"validate": "^[a-f0-9]+$",
+			"confirm":  true,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "API token", p.text)
+		assert.Equal(t, "^[a-f0-9]+$", p.validate.String())
+		assert.True(t, p.confirm)
+
+		errors := map[string]interface{}{
+			"value for secret prompt must be a string or a map":                             []string{"x"},
+			"value for prompt text must be a string":                                        map[string]interface{}{"text": 1},
+			"value for prompt validate must be a string":                                    map[string]interface{}{"validate": 1},
+			"invalid prompt validate pattern: error parsing regexp: missing closing ]: `[`": map[string]interface{}{"validate": "["},
+			"value for prompt confirm must be a boolean":                                    map[string]interface{}{"confirm": "yes"},
+			`unknown prompt option "echo"`:                                                  map[string]interface{}{"echo": true},
+		}
+		for exp, spec := range errors {
+			_, err := parsePrompt(spec)
+			if assert.NotNil(t, err, exp) {
+				assert.Equal(t, exp, err.Error())
+			}
+		}
+	})
+
+	t.Run("value", func(t *testing.T) {
+		var value []byte
+		var err error
+		prompts := withInput([]string{"abc"}, func() {
+			value, err = (&secretPrompt{}).Value("MUSS_TEST_TOKEN")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "abc", string(value))
+		assert.Equal(t, []string{"MUSS_TEST_TOKEN: "}, prompts, "defaults to varname")
+	})
+
+	t.Run("validate and confirm", func(t *testing.T) {
+		p := &secretPrompt{
+			text:     "Token",
+			validate: regexp.MustCompile("^[0-9]+$"),
+			confirm:  true,
+		}
+
+		var value []byte
+		var err error
+		var prompts []string
+		stderr := testutil.CaptureStderr(t, func() {
+			prompts = withInput([]string{"", "123", "124", "123", "123"}, func() {
+				value, err = p.Value("MUSS_TEST_TOKEN")
+			})
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "123", string(value))
+		assert.Equal(t, []string{
+			"Token: ",
+			"Token: ",
+			"Confirm Token: ",
+			"Token: ",
+			"Confirm Token: ",
+		}, prompts)
+		assert.Equal(t, "a value is required\nvalues do not match\n", stderr)
+	})
+
+	t.Run("one at a time", func(t *testing.T) {
+		orig := readPassword
+		defer func() { readPassword = orig }()
+
+		var mutex sync.Mutex
+		active, maxActive := 0, 0
+		readPassword = func(prompt string) ([]byte, error) {
+			mutex.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			active--
+			mutex.Unlock()
+			return []byte("x"), nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				(&secretPrompt{confirm: true}).Value("MUSS_TEST_TOKEN")
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, maxActive)
+	})
+
+	t.Run("waits for the terminal", func(t *testing.T) {
+		// A command has the terminal.
+		terminalMutex.Lock()
+
+		done := make(chan bool)
+		go func() {
+			withInput([]string{"x"}, func() {
+				(&secretPrompt{}).Value("MUSS_TEST_TOKEN")
+			})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("prompted while a command had the terminal")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		terminalMutex.Unlock()
+		<-done
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var err error
+		testutil.CaptureStderr(t, func() {
+			withInput([]string{"a", "b", "c"}, func() {
+				_, err = (&secretPrompt{validate: regexp.MustCompile("^[0-9]+$")}).Value("MUSS_TEST_TOKEN")
+			})
+		})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "no valid value entered for MUSS_TEST_TOKEN (value does not match ^[0-9]+$)", err.Error())
+		}
+
+		withInput(nil, func() {
+			_, err = (&secretPrompt{}).Value("MUSS_TEST_TOKEN")
+		})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "EOF", err.Error())
+		}
+
+		os.Setenv("MUSS_NONINTERACTIVE", "1")
+		defer os.Unsetenv("MUSS_NONINTERACTIVE")
+		_, err = (&secretPrompt{}).Value("MUSS_TEST_TOKEN")
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "cannot prompt for MUSS_TEST_TOKEN in non-interactive mode", err.Error())
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"sort"
+	"sync"
+)
+
+// Values shorter than this are too likely to appear in unrelated output
+// (e.g. "1" or "true") to be worth masking.
+const minSecretValueLength = 4
+
+var secretValues = struct {
+	sync.Mutex
+	values map[string]bool
+}{values: make(map[string]bool)}
+
+func addSecretValue(value string) {
+	if len(value) < minSecretValueLength {
+		return
+	}
+
+	secretValues.Lock()
+	defer secretValues.Unlock()
+
+	secretValues.values[value] = true
+}
+
+// SecretValues returns the values of any secrets that have been loaded
+// into the environment, longest first so that a value
+// containing another value will be masked completely.
+func SecretValues() []string {
+	secretValues.Lock()
+	defer secretValues.Unlock()
+
+	values := make([]string, 0, len(secretValues.values))
+	for v := range secretValues.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	return values
+}
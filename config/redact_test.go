@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretValues(t *testing.T) {
+	os.Unsetenv("MUSS_TEST_REDACT_ONE")
+	os.Unsetenv("MUSS_TEST_REDACT_TWO")
+	os.Unsetenv("MUSS_TEST_REDACT_SHORT")
+	os.Unsetenv("MUSS_TEST_REDACT_PLAIN")
+	os.Setenv("MUSS_TEST_REDACT_SET", "already-set")
+	defer os.Unsetenv("MUSS_TEST_REDACT_ONE")
+	defer os.Unsetenv("MUSS_TEST_REDACT_TWO")
+	defer os.Unsetenv("MUSS_TEST_REDACT_SHORT")
+	defer os.Unsetenv("MUSS_TEST_REDACT_PLAIN")
+	defer os.Unsetenv("MUSS_TEST_REDACT_SET")
+
+	err := loadEnvFromCmds(
+		&secretCmd{
+			cache: "none",
+			EnvCommand: &EnvCommand{
+				Exec:    []string{"echo", "redact-one"},
+				Varname: "MUSS_TEST_REDACT_ONE",
+			},
+		},
+		&secretCmd{
+			cache: "none",
+			EnvCommand: &EnvCommand{
+				Exec:  []string{"echo", "MUSS_TEST_REDACT_TWO=redact-one-two\nMUSS_TEST_REDACT_SHORT=1\nMUSS_TEST_REDACT_SET=not-used"},
+				Parse: true,
+			},
+		},
+		&EnvCommand{
+			Exec:    []string{"echo", "plain-value"},
+			Varname: "MUSS_TEST_REDACT_PLAIN",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := SecretValues()
+	assert.Contains(t, values, "redact-one")
+	assert.Contains(t, values, "redact-one-two")
+	assert.NotContains(t, values, "1", "short values are not tracked")
+	assert.NotContains(t, values, "plain-value", "env commands are not secrets")
+	assert.NotContains(t, values, "not-used", "values that weren't set are not tracked")
+	assert.Equal(t, "already-set", os.Getenv("MUSS_TEST_REDACT_SET"))
+
+	one, two := -1, -1
+	for i, v := range values {
+		switch v {
+		case "redact-one":
+			one = i
+		case "redact-one-two":
+			two = i
+		}
+	}
+	assert.True(t, two < one, "longest first")
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Vars set by secrets and env commands in this process.
+var loadedVars = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+func addLoadedVar(name string) {
+	loadedVars.Lock()
+	defer loadedVars.Unlock()
+
+	loadedVars.names[name] = true
+}
+
+// The hashes are keyed so that the file can't be used to guess values.
+func secretHashFile() string {
+	return path.Join(path.Dir(secretDir), "secret-hashes.json")
+}
+
+func secretHashKeyFile() string {
+	return path.Join(path.Dir(secretDir), "secret-hashes.key")
+}
+
+func secretHashKey() ([]byte, error) {
+	file := secretHashKeyFile()
+	if key, err := ioutil.ReadFile(file); err == nil && len(key) == sha256.Size {
+		return key, nil
+	}
+
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := writePrivateFile(file, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ChangedSecretVars returns the names of the vars loaded by secrets
+// (and env commands) whose values have changed since they were last
+// recorded with RecordSecretVars.
+func ChangedSecretVars() ([]string, error) {
+	loadedVars.Lock()
+	defer loadedVars.Unlock()
+
+	if len(loadedVars.names) == 0 {
+		return nil, nil
+	}
+
+	key, err := secretHashKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret hash key: %s", err)
+	}
+	previous := readSecretHashes()
+
+	changed := make([]string, 0)
+	for name := range loadedVars.names {
+		if old, ok := previous[name]; ok && old != secretHash(key, name) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed, nil
+}
+
+// RecordSecretVars records the current values (as keyed hashes) of the vars
+// loaded by secrets (and env commands) except the ones in skip
+// (which keep their previous values so that they are still reported
+// as changed until the services using them have been recreated).
+func RecordSecretVars(skip []string) error {
+	loadedVars.Lock()
+	defer loadedVars.Unlock()
+
+	if len(loadedVars.names) == 0 {
+		return nil
+	}
+
+	key, err := secretHashKey()
+	if err != nil {
+		return fmt.Errorf("failed to read secret hash key: %s", err)
+	}
+	hashes := readSecretHashes()
+
+	skipped := make(map[string]bool, len(skip))
+	for _, name := range skip {
+		skipped[name] = true
+	}
+
+	updated := false
+	for name := range loadedVars.names {
+		if skipped[name] {
+			continue
+		}
+		if hash := secretHash(key, name); hashes[name] != hash {
+			hashes[name] = hash
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+
+	content, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	if err := writePrivateFile(secretHashFile(), content); err != nil {
+		return fmt.Errorf("failed to save secret hashes: %s", err)
+	}
+	return nil
+}
+
+func readSecretHashes() map[string]string {
+	hashes := make(map[string]string)
+	if content, err := ioutil.ReadFile(secretHashFile()); err == nil {
+		// Start over if it's corrupt.
+		json.Unmarshal(content, &hashes)
+	}
+	return hashes
+}
+
+func secretHash(key []byte, name string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(os.Getenv(name)))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// ServicesUsingVars returns the names of the compose services
+// that reference any of the vars in their config (like "${VAR}")
+// or pass them through from the environment.
+func (cfg *ProjectConfig) ServicesUsingVars(vars []string) ([]string, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	dcc, err := cfg.ComposeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	quoted := make([]string, len(vars))
+	for i, v := range vars {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	names := strings.Join(quoted, "|")
+	reference := regexp.MustCompile(`\$(?:\{(?:` + names + `)[:}?-]|(?:` + names + `)(?:[^A-Za-z0-9_]|$))`)
+	passThrough := regexp.MustCompile(`^(?:` + names + `)$`)
+
+	services := make([]string, 0)
+	if svcs, ok := dcc["services"].(map[string]interface{}); ok {
+		for name, svc := range svcs {
+			if usesVars(svc, reference) || passesThroughVars(svc, passThrough) {
+				services = append(services, name)
+			}
+		}
+	}
+	sort.Strings(services)
+
+	return services, nil
+}
+
+// usesVars looks for variable references in any string in the value.
+func usesVars(v interface{}, reference *regexp.Regexp) bool {
+	switch val := v.(type) {
+	case string:
+		return reference.MatchString(val)
+	case map[string]interface{}:
+		for _, item := range val {
+			if usesVars(item, reference) {
+				return true
+			}
+		}
+	case map[interface{}]interface{}:
+		for _, item := range val {
+			if usesVars(item, reference) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if usesVars(item, reference) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// passesThroughVars looks for environment entries without values
+// (which docker-compose takes from the environment).
+func passesThroughVars(svc interface{}, passThrough *regexp.Regexp) bool {
+	service, ok := svc.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch env := service["environment"].(type) {
+	case map[string]interface{}:
+		for k, v := range env {
+			if v == nil && passThrough.MatchString(k) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range env {
+			if s, ok := item.(string); ok && passThrough.MatchString(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
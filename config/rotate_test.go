@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestRotatedSecrets(t *testing.T) {
+	t.Run("changed vars", func(t *testing.T) {
+		testutil.WithTempDir(t, func(dir string) {
+			findCacheRoot()
+			defer findCacheRoot()
+
+			loadedVars.names = make(map[string]bool)
+			defer func() { loadedVars.names = make(map[string]bool) }()
+
+			changed, err := ChangedSecretVars()
+			assert.Nil(t, err)
+			assert.Empty(t, changed, "nothing loaded")
+			testutil.NoFileExists(t, secretHashFile())
+
+			os.Setenv("MUSS_TEST_ROTATE_A", "rotate-value-a1")
+			os.Setenv("MUSS_TEST_ROTATE_B", "b1")
+			defer os.Unsetenv("MUSS_TEST_ROTATE_A")
+			defer os.Unsetenv("MUSS_TEST_ROTATE_B")
+			addLoadedVar("MUSS_TEST_ROTATE_A")
+			addLoadedVar("MUSS_TEST_ROTATE_B")
+
+			changed, err = ChangedSecretVars()
+			assert.Nil(t, err)
+			assert.Empty(t, changed, "first run")
+			testutil.NoFileExists(t, secretHashFile())
+
+			assert.Nil(t, RecordSecretVars(nil))
+			content := testutil.ReadFile(t, secretHashFile())
+			assert.NotContains(t, content, "rotate-value", "values are hashed")
+
+			os.Setenv("MUSS_TEST_ROTATE_A", "rotate-value-a2")
+			os.Setenv("MUSS_TEST_ROTATE_B", "b2")
+			changed, err = ChangedSecretVars()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"MUSS_TEST_ROTATE_A", "MUSS_TEST_ROTATE_B"}, changed)
+
+			changed, err = ChangedSecretVars()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"MUSS_TEST_ROTATE_A", "MUSS_TEST_ROTATE_B"}, changed, "still changed until recorded")
+
+			assert.Nil(t, RecordSecretVars([]string{"MUSS_TEST_ROTATE_B"}))
+			changed, err = ChangedSecretVars()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"MUSS_TEST_ROTATE_B"}, changed, "skipped vars are not recorded")
+
+			assert.Nil(t, RecordSecretVars(nil))
+			changed, err = ChangedSecretVars()
+			assert.Nil(t, err)
+			assert.Empty(t, changed, "new values recorded")
+		})
+	})
+
+	t.Run("services using vars", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]interface{}{
+			"service_definitions": []map[string]interface{}{
+				map[string]interface{}{
+					"name": "app",
+					"configs": map[string]interface{}{
+						"sole": map[string]interface{}{
+							"services": map[string]interface{}{
+								"map": map[string]interface{}{
+									"image":       "alpine",
+									"environment": map[string]interface{}{"KEY": nil, "OTHER": "x"},
+								},
+								"list": map[string]interface{}{
+									"image":       "alpine",
+									"environment": []interface{}{"FOO=bar", "KEY"},
+								},
+								"ref": map[string]interface{}{
+									"image":   "alpine",
+									"command": []interface{}{"run", "--token=${TOKEN:-none}"},
+								},
+								"bare": map[string]interface{}{
+									"image":       "alpine",
+									"environment": map[string]interface{}{"X": "$TOKEN"},
+								},
+								"none": map[string]interface{}{
+									"image":       "alpine",
+									"environment": map[string]interface{}{"KEYS": nil, "Y": "$TOKENS ${KEYS}"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		services, err := cfg.ServicesUsingVars([]string{"KEY", "TOKEN"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bare", "list", "map", "ref"}, services)
+
+		services, err = cfg.ServicesUsingVars(nil)
+		assert.Nil(t, err)
+		assert.Empty(t, services)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +23,14 @@ type SecretCommand struct {
 	Exec        []string      `yaml:"exec"`
 	EnvCommands []*EnvCommand `yaml:"env_commands"`
 	Passphrase  string        `yaml:"passphrase"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+	// Retries is a pointer so that "retries: 0" can override command_retries.
+	Retries *int `yaml:"retries,omitempty"`
+	// Use an expired cache value if the command fails.
+	StaleOnError bool `yaml:"stale_on_error,omitempty"`
+	// Limit how long past expiration a cache value can be used
+	// (implies stale_on_error).
+	MaxStale time.Duration `yaml:"max_stale,omitempty"`
 }
 
 var secretDir string
@@ -32,6 +41,14 @@ type secretCmd struct {
 	passphrase    string
 	cache         string
 	cacheDuration time.Duration
+	staleOnError  bool
+	maxStale      time.Duration
+	// Identifies the cache entry (defaults to the exec args).
+	cacheID   []string
+	prompt    *secretPrompt
+	generator *secretGenerator
+	audit     bool
+	project   string
 }
 
 func init() {
@@ -67,19 +84,53 @@ func parseSecret(cfg *ProjectConfig, spec map[string]interface{}) (*secretCmd, e
 	var name string
 	var args []string
 	var varname string
-	var parse bool
+	var parse interface{}
+	var field string
+	var fields map[string]string
+	var needs []string
+	var prompt *secretPrompt
+	var generator *secretGenerator
 
 	for k, v := range spec {
 		switch k {
 		case "varname":
 			varname = v.(string)
 		case "parse":
-			parse = v.(bool)
+			parse = v
+		case "field":
+			var ok bool
+			if field, ok = v.(string); !ok {
+				return nil, fmt.Errorf("value for secret field must be a string")
+			}
+		case "fields":
+			var ok bool
+			if fields, ok = stringMap(v); !ok {
+				return nil, fmt.Errorf("value for secret fields must be a map of strings")
+			}
+		case "needs":
+			var ok bool
+			if needs, ok = stringSlice(v); !ok {
+				return nil, fmt.Errorf("value for secret needs must be a list")
+			}
 		default:
 			if name != "" {
 				return nil, fmt.Errorf("secret cannot have multiple commands: %q and %q", name, k)
 			}
 			name = k
+			switch name {
+			case "prompt":
+				var err error
+				if prompt, err = parsePrompt(v); err != nil {
+					return nil, err
+				}
+				continue
+			case "generate":
+				var err error
+				if generator, err = parseGenerator(v); err != nil {
+					return nil, err
+				}
+				continue
+			}
 			var ok bool
 			args, ok = stringSlice(v)
 			if !ok {
@@ -88,11 +139,33 @@ func parseSecret(cfg *ProjectConfig, spec map[string]interface{}) (*secretCmd, e
 		}
 	}
 
+	if prompt != nil || generator != nil {
+		s, err := newBuiltinSecret(cfg, name, varname, parse != nil || field != "" || fields != nil)
+		if err != nil {
+			return nil, err
+		}
+		s.EnvCommand.Needs = needs
+		if prompt != nil {
+			s.prompt = prompt
+			s.cacheID = []string{name, varname, prompt.text}
+		} else {
+			// The options aren't part of the cache id so that the value
+			// stays the same until it is purged.
+			s.generator = generator
+			s.cacheID = []string{name, varname}
+		}
+		return s, nil
+	}
+
 	cmdargs := make([]string, 0)
 
 	// Default to global.
 	passphrase := cfg.SecretPassphrase
+	timeout := cfg.CommandTimeout
+	retries := cfg.CommandRetries
 	var cache string
+	var staleOnError bool
+	var maxStale time.Duration
 
 	// Static command that just runs its args.
 	if name == "exec" {
@@ -109,10 +182,19 @@ func parseSecret(cfg *ProjectConfig, spec map[string]interface{}) (*secretCmd, e
 				}
 
 				cache = command.Cache
+				staleOnError = command.StaleOnError || command.MaxStale > 0
+				maxStale = command.MaxStale
+
+				if command.Timeout != 0 {
+					timeout = command.Timeout
+				}
+				if command.Retries != nil {
+					retries = *command.Retries
+				}
 
 				envCmds := make([]envLoader, len(command.EnvCommands))
 				for i, ec := range command.EnvCommands {
-					envCmds[i] = ec
+					envCmds[i] = ec.withDefaults(timeout, retries)
 				}
 				secretEnvCommands[name] = &secretSetup{envCmds: envCmds}
 			}
@@ -136,15 +218,44 @@ func parseSecret(cfg *ProjectConfig, spec map[string]interface{}) (*secretCmd, e
 	}
 
 	return &secretCmd{
-		name: name,
+		name:    name,
+		cacheID: cmdargs,
 		EnvCommand: &EnvCommand{
 			Exec:    cmdargs,
 			Parse:   parse,
 			Varname: varname,
+			Field:   field,
+			Fields:  fields,
+			Timeout: timeout,
+			Retries: &retries,
+			Needs:   needs,
 		},
 		passphrase:    passphrase,
 		cache:         cache,
 		cacheDuration: cacheDuration,
+		staleOnError:  staleOnError,
+		maxStale:      maxStale,
+		audit:         cfg.AuditLog,
+		project:       AuditProject(),
+	}, nil
+}
+
+// newBuiltinSecret returns a secret whose value comes from muss
+// rather than a command (and is cached using the global passphrase).
+func newBuiltinSecret(cfg *ProjectConfig, name, varname string, parse bool) (*secretCmd, error) {
+	if varname == "" {
+		return nil, fmt.Errorf("%s secrets require a varname", name)
+	}
+	if parse {
+		return nil, fmt.Errorf(`%s secrets cannot use "parse", "field", or "fields"`, name)
+	}
+
+	return &secretCmd{
+		name:       name,
+		EnvCommand: &EnvCommand{Varname: varname},
+		passphrase: cfg.SecretPassphrase,
+		audit:      cfg.AuditLog,
+		project:    AuditProject(),
 	}, nil
 }
 
@@ -162,56 +273,176 @@ func (s *secretCmd) Passphrase() ([]byte, error) {
 	return []byte(expandedPassphrase), nil
 }
 
+func (s *secretCmd) secret() bool {
+	return true
+}
+
 func (s *secretCmd) Value() ([]byte, error) {
+	content, cache, err := s.value()
+	if s.audit {
+		writeAuditEntry(s.auditEntry(cache, err))
+	}
+	return content, err
+}
+
+// value returns the secret and how the cache was used.
+func (s *secretCmd) value() ([]byte, string, error) {
 	if err := runSecretSetup(s.name); err != nil {
-		return nil, err
+		return nil, auditCacheMiss, err
 	}
 
 	if s.cache == "none" {
-		return s.EnvCommand.Value()
+		content, err := s.fetch()
+		return content, auditCacheDisabled, err
 	}
 
 	passphrase, err := s.Passphrase()
 	if err != nil {
-		return nil, err
+		return nil, auditCacheMiss, err
+	}
+
+	// Try the agent first (which avoids decrypting the cache).
+	key := agentKey(passphrase, s.cacheID)
+	if content := agentGet(key); len(content) > 0 {
+		return content, auditCacheHit, nil
 	}
 
 	var content []byte
 
 	// See if we already have the secret cached.
-	cacheFile := path.Join(secretDir, genFileName(s.Exec))
+	cacheFile := s.cacheFile()
+
+	// Let the agent keep it as long as the cache would.
+	agentTTL := s.cacheDuration
 
 	readCache := true
 	if s.cacheDuration > 0 {
 		expiry := time.Now().Add(-s.cacheDuration)
 		info, err := os.Stat(cacheFile)
-		if err == nil && info.ModTime().Before(expiry) {
-			readCache = false
+		if err == nil {
+			if info.ModTime().Before(expiry) {
+				readCache = false
+			} else {
+				agentTTL = info.ModTime().Sub(expiry)
+			}
 		}
-
 	}
 	if readCache {
 		if fileContent, err := ioutil.ReadFile(cacheFile); err == nil {
 			content = s.decrypt(passphrase, fileContent)
+			// Generating a new value would break anything using the old one.
+			if len(content) == 0 && s.generator != nil {
+				return nil, auditCacheMiss, fmt.Errorf("failed to decrypt generated secret %s (has the passphrase changed?); run \"muss secrets purge %s\" to generate a new value", s.Varname, s.Varname)
+			}
 		}
 	}
 
+	cache := auditCacheHit
+
 	// If we don't have a cached value, run the command.
 	if len(content) == 0 {
+		cache = auditCacheMiss
 		var err error
-		content, err = s.EnvCommand.Value()
+		content, err = s.fetch()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get secret: %s", err)
+			if stale := s.staleValue(passphrase, err); len(stale) > 0 {
+				return stale, auditCacheStale, nil
+			}
+			return nil, cache, fmt.Errorf("failed to get secret: %s", err)
 		}
 
 		// Cache it for next time.
+		saveErr := fmt.Errorf("encryption failed")
 		encrypted := s.encrypt(passphrase, content)
 		if len(encrypted) > 0 {
-			writePrivateFile(cacheFile, encrypted)
+			saveErr = writePrivateFile(cacheFile, encrypted)
+			os.Remove(cacheFile + staleSuffix)
+		}
+		// Generated values are only useful if they can be used again.
+		if s.generator != nil && saveErr != nil {
+			return nil, cache, fmt.Errorf("failed to save generated secret %s: %s", s.Varname, saveErr)
+		}
+		agentTTL = s.cacheDuration
+	}
+
+	agentSet(key, content, agentTTL)
+
+	return content, cache, nil
+}
+
+// NeededVars returns the vars that must be set before the secret is loaded
+// (not including any set by its own setup commands, which it runs first).
+func (s *secretCmd) NeededVars() []string {
+	setup := secretEnvCommands[s.name]
+	if setup == nil {
+		return s.Needs
+	}
+
+	provided := make(map[string]bool)
+	for _, ec := range setup.envCmds {
+		vars, _ := ec.ProvidedVars()
+		for _, v := range vars {
+			provided[v] = true
+		}
+	}
+
+	needs := make([]string, 0, len(s.Needs))
+	for _, need := range s.Needs {
+		if !provided[need] {
+			needs = append(needs, need)
 		}
 	}
+	return needs
+}
+
+// fetch gets a new value for the secret.
+func (s *secretCmd) fetch() ([]byte, error) {
+	if s.prompt != nil {
+		return s.prompt.Value(s.Varname)
+	}
+	if s.generator != nil {
+		return s.generator.Value()
+	}
+	return s.EnvCommand.Value()
+}
+
+func (s *secretCmd) cacheFile() string {
+	return path.Join(secretDir, genFileName(s.cacheID))
+}
+
+// staleSuffix is appended to the cache file name to record that an expired
+// value was used (and why).
+const staleSuffix = ".stale"
+
+// staleValue returns the expired cache value (if allowed) when the command
+// fails to refresh it.
+func (s *secretCmd) staleValue(passphrase []byte, cmdErr error) []byte {
+	if !s.staleOnError {
+		return nil
+	}
+
+	cacheFile := s.cacheFile()
+	info, err := os.Stat(cacheFile)
+	if err != nil {
+		return nil
+	}
+	if s.maxStale > 0 && time.Since(info.ModTime().Add(s.cacheDuration)) > s.maxStale {
+		return nil
+	}
+
+	fileContent, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return nil
+	}
+	content := s.decrypt(passphrase, fileContent)
+	if len(content) == 0 {
+		return nil
+	}
 
-	return content, nil
+	fmt.Fprintf(os.Stderr, "Warning: using stale cached value for %s: %s\n", s.description(), cmdErr)
+	writePrivateFile(cacheFile+staleSuffix, []byte(cmdErr.Error()))
+
+	return content
 }
 
 var secretSetupMutex sync.Mutex
@@ -327,3 +558,134 @@ func writePrivateFile(file string, bytes []byte) error {
 	}
 	return ioutil.WriteFile(file, bytes, 0600)
 }
+
+// Secret cache states reported by SecretStatuses.
+const (
+	SecretCacheDisabled = "disabled"
+	SecretCacheMissing  = "missing"
+	SecretCacheCached   = "cached"
+	SecretCacheExpired  = "expired"
+	SecretCacheStale    = "stale"
+)
+
+// SecretStatus describes a configured secret and the state of its cache.
+type SecretStatus struct {
+	Name    string
+	Command string
+	Cache   string
+	Updated time.Time
+	// Error is the reason a stale value was used.
+	Error string
+}
+
+// SecretStatuses returns the status of each secret in the config
+// without running any commands.
+func (cfg *ProjectConfig) SecretStatuses() ([]SecretStatus, error) {
+	if err := cfg.loadComposeConfig(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]SecretStatus, 0, len(cfg.Secrets))
+	for _, loader := range cfg.Secrets {
+		s, ok := loader.(*secretCmd)
+		if !ok {
+			continue
+		}
+		statuses = append(statuses, s.status())
+	}
+	return statuses, nil
+}
+
+func (s *secretCmd) status() SecretStatus {
+	status := SecretStatus{
+		Name:    s.statusName(),
+		Command: s.name,
+		Cache:   SecretCacheMissing,
+	}
+
+	if s.cache == "none" {
+		status.Cache = SecretCacheDisabled
+		return status
+	}
+
+	cacheFile := s.cacheFile()
+	info, err := os.Stat(cacheFile)
+	if err != nil {
+		return status
+	}
+	status.Updated = info.ModTime()
+	status.Cache = SecretCacheCached
+
+	if reason, err := ioutil.ReadFile(cacheFile + staleSuffix); err == nil {
+		status.Cache = SecretCacheStale
+		status.Error = string(reason)
+	} else if s.cacheDuration > 0 && time.Since(info.ModTime()) > s.cacheDuration {
+		status.Cache = SecretCacheExpired
+	}
+
+	return status
+}
+
+// statusName returns the var names that the secret sets (when known).
+func (s *secretCmd) statusName() string {
+	if s.Varname != "" {
+		return s.Varname
+	}
+	if len(s.Fields) > 0 {
+		names := make([]string, 0, len(s.Fields))
+		for _, k := range sortedKeys(s.Fields) {
+			names = append(names, s.Fields[k])
+		}
+		return strings.Join(names, ",")
+	}
+	return "(parsed)"
+}
+
+// PurgeSecrets removes the cached values of the secrets with the provided
+// names (or of all secrets if no names are provided)
+// and returns the names of the secrets that had cached values.
+func (cfg *ProjectConfig) PurgeSecrets(names ...string) ([]string, error) {
+	if err := cfg.loadComposeConfig(); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = false
+	}
+
+	purged := make([]string, 0)
+	for _, loader := range cfg.Secrets {
+		s, ok := loader.(*secretCmd)
+		if !ok {
+			continue
+		}
+		name := s.statusName()
+		if len(names) > 0 {
+			if _, ok := selected[name]; !ok {
+				continue
+			}
+			selected[name] = true
+		}
+
+		cacheFile := s.cacheFile()
+		os.Remove(cacheFile + staleSuffix)
+		if err := os.Remove(cacheFile); err == nil {
+			purged = append(purged, name)
+		} else if !os.IsNotExist(err) {
+			return purged, err
+		}
+	}
+
+	for _, name := range names {
+		if !selected[name] {
+			return purged, fmt.Errorf("no secret named %q", name)
+		}
+	}
+
+	// The agent can't tell which values belong to which secret
+	// so clear them all.
+	agentLock()
+
+	return purged, nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"gerrit.instructure.com/muss/agent"
 	"gerrit.instructure.com/muss/testutil"
 )
 
@@ -327,6 +328,347 @@ func TestSecretCommands(t *testing.T) {
 			assert.Equal(t, "$MUSS_TEST_FOO", foo.passphrase, "secret-command-specific")
 			assert.Equal(t, "$MUSS_TEST_PASSPHRASE", bar.passphrase, "global")
 		})
+
+		t.Run("timeouts and retries", func(t *testing.T) {
+			cfg := &ProjectConfig{
+				CommandTimeout: time.Minute,
+				CommandRetries: 1,
+				SecretCommands: map[string]*SecretCommand{
+					"fast": &SecretCommand{
+						Exec:    []string{"echo"},
+						Timeout: time.Second,
+						EnvCommands: []*EnvCommand{
+							&EnvCommand{Exec: []string{"echo"}, Varname: "MUSS_TEST_A", Retries: intPtr(3)},
+						},
+					},
+					"once": &SecretCommand{
+						Exec:    []string{"echo"},
+						Retries: intPtr(0),
+						EnvCommands: []*EnvCommand{
+							&EnvCommand{Exec: []string{"echo"}, Varname: "MUSS_TEST_A"},
+						},
+					},
+				},
+			}
+
+			global, err := parseSecret(cfg, map[string]interface{}{"exec": []string{"echo"}, "varname": "MUSS_TEST_A"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, time.Minute, global.Timeout, "global")
+			assert.Equal(t, 1, *global.Retries, "global")
+
+			fast, err := parseSecret(cfg, map[string]interface{}{"fast": []string{}, "varname": "MUSS_TEST_A"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, time.Second, fast.Timeout, "secret command")
+			assert.Equal(t, 1, *fast.Retries, "global")
+
+			setup := secretEnvCommands["fast"].envCmds[0].(*EnvCommand)
+			assert.Equal(t, time.Second, setup.Timeout, "secret command")
+			assert.Equal(t, 3, *setup.Retries, "env command")
+
+			once, err := parseSecret(cfg, map[string]interface{}{"once": []string{}, "varname": "MUSS_TEST_A"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 0, *once.Retries, "secret command overrides global with 0")
+			setup = secretEnvCommands["once"].envCmds[0].(*EnvCommand)
+			assert.Equal(t, 0, *setup.Retries, "env command inherits 0")
+		})
+
+		t.Run("structured output", func(t *testing.T) {
+			cfg := &ProjectConfig{}
+
+			s, err := parseSecret(cfg, map[string]interface{}{
+				"exec":  []string{"echo", "{}"},
+				"parse": "json",
+				"fields": map[string]interface{}{
+					"data.user": "MUSS_TEST_USER",
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			format, err := s.ParseFormat()
+			assert.Nil(t, err)
+			assert.Equal(t, "json", format)
+			assert.Equal(t, map[string]string{"data.user": "MUSS_TEST_USER"}, s.FieldMap())
+
+			s, err = parseSecret(cfg, map[string]interface{}{
+				"exec":    []string{"echo", "{}"},
+				"parse":   "yaml",
+				"field":   "data.password",
+				"varname": "MUSS_TEST_PASSWORD",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "data.password", s.FieldName())
+
+			_, err = parseSecret(cfg, map[string]interface{}{
+				"exec":   []string{"echo", "{}"},
+				"parse":  "json",
+				"fields": map[string]interface{}{"a": 1},
+			})
+			assert.Equal(t, "value for secret fields must be a map of strings", err.Error())
+		})
+
+		t.Run("agent", func(t *testing.T) {
+			os.Setenv("MUSS_TEST_PASSPHRASE", "agent")
+			os.Unsetenv("MUSS_TEST_AGENT")
+			defer os.Unsetenv("MUSS_TEST_AGENT")
+
+			socket := path.Join(tmpdir, "agent.sock")
+			os.Setenv(agent.SocketEnvVar, socket)
+			defer os.Unsetenv(agent.SocketEnvVar)
+
+			listener, err := agent.Listen(socket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			a := agent.New(time.Hour)
+			go a.Serve(listener)
+
+			count := testutil.TempFile(t, "", "muss-agent")
+			count.Close()
+			defer os.Remove(count.Name())
+
+			secret, err := parseSecret(&ProjectConfig{SecretPassphrase: "$MUSS_TEST_PASSPHRASE"}, map[string]interface{}{
+				"exec":    []string{"/bin/sh", "-c", `echo x >> "$0"; echo agent-value`, count.Name()},
+				"varname": "MUSS_TEST_AGENT",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testLoadSecret(t, secret)
+			assert.Equal(t, "agent-value", os.Getenv("MUSS_TEST_AGENT"))
+
+			// Without the disk cache the value comes from the agent.
+			os.Remove(secret.cacheFile())
+			os.Unsetenv("MUSS_TEST_AGENT")
+			testLoadSecret(t, secret)
+			assert.Equal(t, "agent-value", os.Getenv("MUSS_TEST_AGENT"))
+			assert.Equal(t, "x\n", testutil.ReadFile(t, count.Name()), "command only ran once")
+
+			// A different passphrase is a different key.
+			os.Setenv("MUSS_TEST_PASSPHRASE", "other")
+			os.Unsetenv("MUSS_TEST_AGENT")
+			testLoadSecret(t, secret)
+			assert.Equal(t, "x\nx\n", testutil.ReadFile(t, count.Name()), "passphrase changed")
+
+			a.Lock()
+			os.Remove(secret.cacheFile())
+			os.Unsetenv("MUSS_TEST_AGENT")
+			testLoadSecret(t, secret)
+			assert.Equal(t, "x\nx\nx\n", testutil.ReadFile(t, count.Name()), "agent locked")
+		})
+
+		t.Run("prompt", func(t *testing.T) {
+			os.Setenv("MUSS_TEST_PASSPHRASE", "prompt")
+			os.Unsetenv("MUSS_TEST_PROMPTED")
+			defer os.Unsetenv("MUSS_TEST_PROMPTED")
+
+			cfg := &ProjectConfig{SecretPassphrase: "$MUSS_TEST_PASSPHRASE"}
+			secret, err := parseSecret(cfg, map[string]interface{}{
+				"prompt":  map[string]interface{}{"text": "Personal token"},
+				"varname": "MUSS_TEST_PROMPTED",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			prompts := withInput([]string{"t0k3n"}, func() {
+				testLoadSecret(t, secret)
+			})
+			assert.Equal(t, "t0k3n", os.Getenv("MUSS_TEST_PROMPTED"))
+			assert.Equal(t, []string{"Personal token: "}, prompts)
+			assert.FileExists(t, secret.cacheFile())
+
+			os.Unsetenv("MUSS_TEST_PROMPTED")
+			prompts = withInput(nil, func() {
+				testLoadSecret(t, secret)
+			})
+			assert.Equal(t, "t0k3n", os.Getenv("MUSS_TEST_PROMPTED"), "cached")
+			assert.Equal(t, []string{}, prompts, "not prompted again")
+
+			_, err = parseSecret(cfg, map[string]interface{}{"prompt": "Token"})
+			assert.Equal(t, "prompt secrets require a varname", err.Error())
+
+			_, err = parseSecret(cfg, map[string]interface{}{"prompt": "Token", "varname": "X", "parse": true})
+			assert.Equal(t, `prompt secrets cannot use "parse", "field", or "fields"`, err.Error())
+
+			_, err = parseSecret(cfg, map[string]interface{}{"prompt": "Token", "exec": []string{"echo"}})
+			assert.Regexp(t, `secret cannot have multiple commands: ("prompt" and "exec"|"exec" and "prompt")`, err.Error())
+		})
+
+		t.Run("generate", func(t *testing.T) {
+			os.Setenv("MUSS_TEST_PASSPHRASE", "generate")
+			os.Unsetenv("MUSS_TEST_GENERATED")
+			defer os.Unsetenv("MUSS_TEST_GENERATED")
+
+			cfg := &ProjectConfig{SecretPassphrase: "$MUSS_TEST_PASSPHRASE"}
+			secret, err := parseSecret(cfg, map[string]interface{}{
+				"generate": map[string]interface{}{"format": "hex", "length": 16},
+				"varname":  "MUSS_TEST_GENERATED",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Secrets = []envLoader{secret}
+
+			testLoadSecret(t, secret)
+			generated := os.Getenv("MUSS_TEST_GENERATED")
+			assert.Regexp(t, `^[0-9a-f]{16}$`, generated)
+
+			os.Unsetenv("MUSS_TEST_GENERATED")
+			testLoadSecret(t, secret)
+			assert.Equal(t, generated, os.Getenv("MUSS_TEST_GENERATED"), "persisted")
+
+			os.Setenv("MUSS_TEST_PASSPHRASE", "changed")
+			os.Unsetenv("MUSS_TEST_GENERATED")
+			assert.Equal(t,
+				`failed to decrypt generated secret MUSS_TEST_GENERATED (has the passphrase changed?); run "muss secrets purge MUSS_TEST_GENERATED" to generate a new value`,
+				loadEnvFromCmds(secret).Error())
+
+			_, err = cfg.PurgeSecrets("MUSS_TEST_NOPE")
+			assert.Equal(t, `no secret named "MUSS_TEST_NOPE"`, err.Error())
+
+			purged, err := cfg.PurgeSecrets("MUSS_TEST_GENERATED")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"MUSS_TEST_GENERATED"}, purged)
+			testutil.NoFileExists(t, secret.cacheFile())
+
+			testLoadSecret(t, secret)
+			assert.Regexp(t, `^[0-9a-f]{16}$`, os.Getenv("MUSS_TEST_GENERATED"))
+			assert.NotEqual(t, generated, os.Getenv("MUSS_TEST_GENERATED"), "new value")
+
+			_, err = parseSecret(cfg, map[string]interface{}{"generate": nil})
+			assert.Equal(t, "generate secrets require a varname", err.Error())
+		})
+
+		t.Run("needs", func(t *testing.T) {
+			cfg := &ProjectConfig{
+				SecretCommands: map[string]*SecretCommand{
+					"vault": &SecretCommand{
+						Exec: []string{"echo"},
+						EnvCommands: []*EnvCommand{
+							&EnvCommand{Exec: []string{"echo"}, Varname: "MUSS_TEST_VAULT_TOKEN"},
+						},
+					},
+				},
+			}
+
+			s, err := parseSecret(cfg, map[string]interface{}{
+				"vault":   []string{"x"},
+				"varname": "MUSS_TEST_X",
+				"needs":   []interface{}{"MUSS_TEST_VAULT_TOKEN", "MUSS_TEST_LOGIN"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, []string{"MUSS_TEST_LOGIN"}, s.NeededVars(), "setup commands run first")
+
+			_, err = parseSecret(cfg, map[string]interface{}{
+				"exec":  []string{"echo"},
+				"needs": "MUSS_TEST_LOGIN",
+			})
+			assert.Equal(t, "value for secret needs must be a list", err.Error())
+		})
+
+		t.Run("stale on error", func(t *testing.T) {
+			os.Setenv("MUSS_TEST_PASSPHRASE", "stale")
+			os.Unsetenv("MUSS_TEST_FAIL")
+			os.Unsetenv("MUSS_TEST_STALE")
+			defer os.Unsetenv("MUSS_TEST_STALE")
+
+			cfg := &ProjectConfig{
+				SecretPassphrase: "$MUSS_TEST_PASSPHRASE",
+				SecretCommands: map[string]*SecretCommand{
+					"flaky": &SecretCommand{
+						Exec:         []string{"/bin/sh", "-c", `[ -z "$MUSS_TEST_FAIL" ] && echo "$0" || { echo vault down >&2; exit 1; }`},
+						Cache:        "1h",
+						StaleOnError: true,
+					},
+				},
+			}
+			spec := map[string]interface{}{
+				"flaky":   []string{"old-value"},
+				"varname": "MUSS_TEST_STALE",
+			}
+
+			secret, err := parseSecret(cfg, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cacheFile := secret.cacheFile()
+
+			statuses := func() []SecretStatus {
+				t.Helper()
+				cfg.Secrets = []envLoader{secret}
+				statuses, err := cfg.SecretStatuses()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return statuses
+			}
+
+			assert.Equal(t, SecretCacheMissing, statuses()[0].Cache)
+
+			testLoadSecret(t, secret)
+			assert.Equal(t, "old-value", os.Getenv("MUSS_TEST_STALE"))
+			assert.Equal(t, SecretCacheCached, statuses()[0].Cache)
+
+			expired := time.Now().Add(-2 * time.Hour)
+			if err := os.Chtimes(cacheFile, expired, expired); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, SecretCacheExpired, statuses()[0].Cache)
+
+			os.Setenv("MUSS_TEST_FAIL", "1")
+			defer os.Unsetenv("MUSS_TEST_FAIL")
+
+			os.Unsetenv("MUSS_TEST_STALE")
+			stderr := testutil.CaptureStderr(t, func() {
+				testLoadSecret(t, secret)
+			})
+			assert.Equal(t, "old-value", os.Getenv("MUSS_TEST_STALE"), "stale value")
+			assert.Contains(t, stderr, "Warning: using stale cached value for MUSS_TEST_STALE: command failed: exit status 1")
+
+			status := statuses()[0]
+			assert.Equal(t, "MUSS_TEST_STALE", status.Name)
+			assert.Equal(t, "flaky", status.Command)
+			assert.Equal(t, SecretCacheStale, status.Cache)
+			assert.Equal(t, "command failed: exit status 1", status.Error)
+
+			// Too old.
+			cfg.SecretCommands["flaky"].MaxStale = 30 * time.Minute
+			secret, err = parseSecret(cfg, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Unsetenv("MUSS_TEST_STALE")
+			assert.Equal(t,
+				"failed to get secret: command failed: exit status 1",
+				loadEnvFromCmds(secret).Error(),
+				"past max_stale")
+
+			// Refreshing clears the stale marker.
+			os.Unsetenv("MUSS_TEST_FAIL")
+			testLoadSecret(t, secret)
+			assert.Equal(t, SecretCacheCached, statuses()[0].Cache)
+			testutil.NoFileExists(t, cacheFile+staleSuffix)
+
+			cfg.SecretCommands["flaky"].Cache = "none"
+			secret, err = parseSecret(cfg, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, SecretCacheDisabled, statuses()[0].Cache)
+		})
 	})
 
 	t.Run("errors", func(t *testing.T) {
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prefixes of service selectors.
+const (
+	// TagSelector selects the services of the service definitions with a tag.
+	TagSelector = "@"
+	// DefinitionSelector selects the services of a service definition.
+	DefinitionSelector = "def:"
+	// ExcludeSelector removes the services of what follows it.
+	ExcludeSelector = "!"
+)
+
+// IsServiceSelector returns true if the arg selects services
+// (rather than naming one).
+func IsServiceSelector(arg string) bool {
+	return strings.HasPrefix(arg, TagSelector) ||
+		strings.HasPrefix(arg, DefinitionSelector) ||
+		strings.HasPrefix(arg, ExcludeSelector)
+}
+
+// ExpandServices replaces any selectors in the args with the names of the
+// compose services they select.  "@tag" selects the services of the service
+// definitions with that tag, "def:name" selects the services of that service
+// definition, and "!" before a name or selector excludes those services
+// (from all the services if nothing else is selected).
+// Other args are kept as they are (and the args are returned unchanged if
+// there are no selectors).
+func (cfg *ProjectConfig) ExpandServices(args []string) ([]string, error) {
+	hasSelector := false
+	for _, arg := range args {
+		hasSelector = hasSelector || IsServiceSelector(arg)
+	}
+	if !hasSelector {
+		return args, nil
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("service selectors require a project config")
+	}
+
+	all, err := cfg.composeServices()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]string, 0, len(args))
+	excluded := make(map[string]bool)
+	excludes := false
+	for _, arg := range args {
+		exclude := strings.HasPrefix(arg, ExcludeSelector)
+		names, err := cfg.selectServices(strings.TrimPrefix(arg, ExcludeSelector), all)
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			excludes = true
+			for _, name := range names {
+				excluded[name] = true
+			}
+		} else {
+			selected = append(selected, names...)
+		}
+	}
+	if len(selected) == 0 && excludes {
+		selected = all
+	}
+
+	result := make([]string, 0, len(selected))
+	seen := make(map[string]bool)
+	for _, name := range selected {
+		if !seen[name] && !excluded[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no services selected by '%s'", strings.Join(args, " "))
+	}
+	return result, nil
+}
+
+// selectServices returns the names of the services the arg selects.
+func (cfg *ProjectConfig) selectServices(arg string, all []string) ([]string, error) {
+	existing := make(map[string]bool, len(all))
+	for _, name := range all {
+		existing[name] = true
+	}
+	present := func(names []string) []string {
+		result := make([]string, 0, len(names))
+		for _, name := range names {
+			if existing[name] {
+				result = append(result, name)
+			}
+		}
+		return result
+	}
+
+	switch {
+	case strings.HasPrefix(arg, TagSelector):
+		tag := strings.TrimPrefix(arg, TagSelector)
+		found := false
+		names := make([]string, 0)
+		for _, def := range cfg.ServiceDefinitions {
+			for _, t := range def.Tags {
+				if t == tag {
+					found = true
+					names = append(names, cfg.definitionServices[def.Name]...)
+					break
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no service definitions have the tag '%s'", tag)
+		}
+		sort.Strings(names)
+		return present(names), nil
+	case strings.HasPrefix(arg, DefinitionSelector):
+		name := strings.TrimPrefix(arg, DefinitionSelector)
+		for _, def := range cfg.ServiceDefinitions {
+			if def.Name == name {
+				return present(cfg.definitionServices[name]), nil
+			}
+		}
+		return nil, fmt.Errorf("service definition '%s' not found", name)
+	case arg == "":
+		return nil, fmt.Errorf("invalid service selector '%s'", ExcludeSelector)
+	default:
+		return []string{arg}, nil
+	}
+}
+
+// composeServices returns the (sorted) names of the services
+// in the compose config.
+func (cfg *ProjectConfig) composeServices() ([]string, error) {
+	dcc, err := cfg.ComposeConfig()
+	if err != nil {
+		return nil, err
+	}
+	services, _ := dcc["services"].(map[string]interface{})
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
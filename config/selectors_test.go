@@ -0,0 +1,98 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandServices(t *testing.T) {
+	_, cfg, err := parseAndCompose(`
+service_definitions:
+- name: app
+  tags: [backend]
+  configs:
+    sole:
+      services:
+        app: {image: app}
+        worker: {image: app}
+- name: microservice
+  tags: [backend, api]
+  configs:
+    sole:
+      services:
+        ms: {image: ms}
+        # Only adds to app.
+        app: {environment: {MS_URL: http://ms}}
+- name: web
+  configs:
+    sole:
+      services:
+        web: {build: .}
+- name: extra
+  tags: [extra]
+  configs:
+    sole:
+      services:
+        extra: {image: extra}
+user:
+  services:
+    extra: {disabled: true}
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expand := func(args ...string) []string {
+		t.Helper()
+		services, err := cfg.ExpandServices(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return services
+	}
+
+	t.Run("no selectors", func(t *testing.T) {
+		assert.Equal(t, []string{"web", "nope"}, expand("web", "nope"))
+		assert.Empty(t, expand())
+
+		var none *ProjectConfig
+		services, err := none.ExpandServices([]string{"web"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"web"}, services)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		assert.Equal(t, []string{"app", "ms", "worker"}, expand("@backend"))
+		assert.Equal(t, []string{"web", "ms"}, expand("web", "@api"))
+		assert.Equal(t, []string{"ms", "app", "worker"}, expand("@api", "@backend"), "no duplicates")
+	})
+
+	t.Run("definitions", func(t *testing.T) {
+		assert.Equal(t, []string{"ms"}, expand("def:microservice"), "not services it only adds to")
+		assert.Equal(t, []string{"app", "worker", "web"}, expand("def:app", "web"))
+	})
+
+	t.Run("exclusions", func(t *testing.T) {
+		assert.Equal(t, []string{"app", "ms", "web"}, expand("!worker"), "from all services")
+		assert.Equal(t, []string{"app", "ms"}, expand("@backend", "!worker"))
+		assert.Equal(t, []string{"web"}, expand("!@backend"))
+		assert.Equal(t, []string{"app", "web", "worker"}, expand("!def:microservice"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assertError := func(exp string, args ...string) {
+			t.Helper()
+			_, err := cfg.ExpandServices(args)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, exp, err.Error())
+			}
+		}
+
+		assertError("no service definitions have the tag 'nope'", "@nope")
+		assertError("service definition 'nope' not found", "def:nope")
+		assertError("invalid service selector '!'", "!")
+		assertError("no services selected by '@extra'", "@extra")
+		assertError("no services selected by '@api !ms'", "@api", "!ms")
+	})
+}
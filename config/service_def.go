@@ -12,6 +12,7 @@ type ServiceDef struct {
 	Configs map[string]interface{} `yaml:"configs"`
 	File    string                 `yaml:"file"`
 	Name    string                 `yaml:"name"`
+	Tags    []string               `yaml:"tags,omitempty"`
 }
 
 func newServiceDef(file string) *ServiceDef {
@@ -104,6 +105,19 @@ func (s *ServiceDef) chooseConfig(cfg *ProjectConfig) (map[string]interface{}, e
 	return result, nil
 }
 
+// hasHooks returns true if any of the configs (including bases)
+// define hooks (without having to choose one).
+func (s *ServiceDef) hasHooks() bool {
+	for _, c := range s.Configs {
+		if m, ok := c.(map[string]interface{}); ok {
+			if _, ok := m["hooks"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *ServiceDef) configOptions() []string {
 	keys := make([]string, 0, len(s.Configs))
 	for k := range s.Configs {
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Vars provided by the parsed output of secrets and env commands
+// (whether or not they were already set).
+var providedVars = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+func addProvidedVar(name string) {
+	providedVars.Lock()
+	defer providedVars.Unlock()
+
+	providedVars.names[name] = true
+}
+
+// LoadedEnv returns the env vars that the config loads (COMPOSE_* vars and
+// those provided by secrets and env commands) with their current values
+// (which may have been set before muss ran, like under "muss wrap").
+// Call it after the env has been loaded (by Save).
+func (cfg *ProjectConfig) LoadedEnv() map[string]string {
+	names := []string{"COMPOSE_PROJECT_NAME", "COMPOSE_FILE"}
+	for _, loader := range cfg.Secrets {
+		if vars, ok := loader.ProvidedVars(); ok {
+			names = append(names, vars...)
+		}
+	}
+	providedVars.Lock()
+	for name := range providedVars.names {
+		names = append(names, name)
+	}
+	providedVars.Unlock()
+
+	env := make(map[string]string, len(names))
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// ServiceEnv returns the env of the compose service from its "env_file"
+// and "environment" (interpolated with the current env like compose does).
+func (cfg *ProjectConfig) ServiceEnv(name string) (map[string]string, error) {
+	dcc, err := cfg.ComposeConfig()
+	if err != nil {
+		return nil, err
+	}
+	services, _ := dcc["services"].(map[string]interface{})
+	service, ok := services[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("service '%s' not found", name)
+	}
+
+	env := make(map[string]string)
+
+	files, err := envFiles(service["env_file"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid env_file for %s: %w", name, err)
+	}
+	dir := filepath.Dir(cfg.ComposeFilePath())
+	for _, envFile := range files {
+		file, err := interpolate(envFile.path)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		content, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) && envFile.optional {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		parsed, err := parseDotenvOutput(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		fileEnv, err := envFromParsed(parsed)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileEnv {
+			env[k] = v
+		}
+	}
+
+	switch environment := service["environment"].(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, v := range environment {
+			if v == nil {
+				setFromEnv(env, k)
+				continue
+			}
+			value, ok := scalarString(v)
+			if !ok {
+				return nil, fmt.Errorf("invalid environment for %s: value for %q is not a string", name, k)
+			}
+			if env[k], err = interpolate(value); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for _, item := range environment {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid environment for %s: %v is not a string", name, item)
+			}
+			parts := strings.SplitN(s, "=", 2)
+			if len(parts) == 1 {
+				setFromEnv(env, parts[0])
+				continue
+			}
+			if env[parts[0]], err = interpolate(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid environment for %s: must be a map or a list", name)
+	}
+
+	return env, nil
+}
+
+// setFromEnv copies the var from the current env (if it is set)
+// like compose does for names without values.
+func setFromEnv(env map[string]string, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		env[name] = value
+	} else {
+		delete(env, name)
+	}
+}
+
+// envFile is an entry of "env_file".
+type envFile struct {
+	path string
+	// optional files are skipped if they don't exist.
+	optional bool
+}
+
+// envFiles returns the files from an "env_file" value
+// (a string, or a list of strings or maps with a "path").
+func envFiles(value interface{}) ([]envFile, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []envFile{{path: v}}, nil
+	case []interface{}:
+		files := make([]envFile, 0, len(v))
+		for _, item := range v {
+			switch file := item.(type) {
+			case string:
+				files = append(files, envFile{path: file})
+			case map[string]interface{}:
+				path, ok := file["path"].(string)
+				if !ok {
+					return nil, fmt.Errorf("env_file entry must have a path")
+				}
+				required, ok := file["required"].(bool)
+				files = append(files, envFile{path: path, optional: ok && !required})
+			default:
+				return nil, fmt.Errorf("env_file entries must be strings or maps")
+			}
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("must be a string or a list")
+}
+
+var reInterpolation = regexp.MustCompile(`\$(?:(\$)|([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\})`)
+
+// interpolate replaces variables in the value the way compose does:
+// $VAR, ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error}
+// and "$$" for a literal "$".
+func interpolate(value string) (string, error) {
+	var err error
+	result := reInterpolation.ReplaceAllStringFunc(value, func(match string) string {
+		m := reInterpolation.FindStringSubmatch(match)
+		if m[1] != "" {
+			return "$"
+		}
+		name := m[2] + m[3]
+		val, set := os.LookupEnv(name)
+		op, arg := m[4], m[5]
+		// With a colon empty values count as unset.
+		unset := !set || (strings.HasPrefix(op, ":") && val == "")
+		switch strings.TrimPrefix(op, ":") {
+		case "-":
+			if unset {
+				return arg
+			}
+		case "?":
+			if unset && err == nil {
+				err = fmt.Errorf("required variable %s is missing a value: %s", name, arg)
+			}
+		}
+		return val
+	})
+	return result, err
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("MUSS_TEST_SET", "val")
+	os.Setenv("MUSS_TEST_EMPTY", "")
+	os.Unsetenv("MUSS_TEST_UNSET")
+	defer os.Unsetenv("MUSS_TEST_SET")
+	defer os.Unsetenv("MUSS_TEST_EMPTY")
+
+	for value, exp := range map[string]string{
+		"plain":                               "plain",
+		"$MUSS_TEST_SET and ${MUSS_TEST_SET}": "val and val",
+		"$$MUSS_TEST_SET":                     "$MUSS_TEST_SET",
+		"[$MUSS_TEST_UNSET]":                  "[]",
+		"${MUSS_TEST_UNSET-def}":              "def",
+		"${MUSS_TEST_EMPTY-def}":              "",
+		"${MUSS_TEST_EMPTY:-def}":             "def",
+		"${MUSS_TEST_SET:-def}":               "val",
+		"${MUSS_TEST_SET?err}":                "val",
+	} {
+		actual, err := interpolate(value)
+		assert.Nil(t, err)
+		assert.Equal(t, exp, actual, value)
+	}
+
+	_, err := interpolate("${MUSS_TEST_EMPTY:?is required}")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "required variable MUSS_TEST_EMPTY is missing a value: is required", err.Error())
+	}
+}
+
+func TestLoadedEnv(t *testing.T) {
+	os.Setenv("MUSS_TEST_LOADED_SET", "from outside")
+	os.Unsetenv("MUSS_TEST_LOADED_NEW")
+	defer os.Unsetenv("MUSS_TEST_LOADED_SET")
+	defer os.Unsetenv("MUSS_TEST_LOADED_NEW")
+
+	cfg := &ProjectConfig{}
+	cfg.Secrets = append(cfg.Secrets, &EnvCommand{
+		Exec:  []string{"echo", "MUSS_TEST_LOADED_SET=parsed\nMUSS_TEST_LOADED_NEW=new"},
+		Parse: true,
+	})
+	if err := cfg.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := cfg.LoadedEnv()
+	assert.Equal(t, "from outside", env["MUSS_TEST_LOADED_SET"], "already set (like under muss wrap)")
+	assert.Equal(t, "new", env["MUSS_TEST_LOADED_NEW"])
+}
+
+func TestServiceEnv(t *testing.T) {
+	testutil.WithTempDir(t, func(dir string) {
+		testutil.WriteFile(t, "one.env", "A=1\nB=1\n")
+		testutil.WriteFile(t, "two.env", "B=2\nC=2\n")
+		os.Setenv("MUSS_TEST_PASSED", "through")
+		defer os.Unsetenv("MUSS_TEST_PASSED")
+
+		_, cfg, err := parseAndCompose(`
+service_definitions:
+- name: app
+  configs:
+    sole:
+      services:
+        list:
+          image: app
+          env_file: [one.env, {path: two.env}, {path: missing.env, required: false}]
+          environment: [C=3, MUSS_TEST_PASSED, MUSS_TEST_UNSET]
+        map:
+          image: app
+          env_file: one.env
+          environment:
+            A: 2
+            MUSS_TEST_PASSED:
+        none:
+          image: app
+        bad:
+          image: app
+          environment: 1
+`)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		env, err := cfg.ServiceEnv("list")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"A": "1", "B": "2", "C": "3", "MUSS_TEST_PASSED": "through"}, env)
+
+		env, err = cfg.ServiceEnv("map")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"A": "2", "B": "1", "MUSS_TEST_PASSED": "through"}, env)
+
+		env, err = cfg.ServiceEnv("none")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{}, env)
+
+		_, err = cfg.ServiceEnv("bad")
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "invalid environment for bad: must be a map or a list", err.Error())
+		}
+	})
+}
@@ -0,0 +1,215 @@
+// Package engine is a small client for the Docker Engine API
+// (also served by podman) used to find the containers of a compose project.
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels that compose sets on the containers it creates.
+const (
+	LabelProject = "com.docker.compose.project"
+	LabelService = "com.docker.compose.service"
+	LabelNumber  = "com.docker.compose.container-number"
+	LabelOneOff  = "com.docker.compose.oneoff"
+)
+
+// ErrUnavailable is returned (wrapped) when the engine can't be reached.
+var ErrUnavailable = errors.New("container engine is not available")
+
+// Container is a container as listed by the engine.
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// Service returns the name of the compose service of the container.
+func (c *Container) Service() string {
+	return c.Labels[LabelService]
+}
+
+// Number returns the compose container number (or 0 if it doesn't have one).
+func (c *Container) Number() int {
+	n, _ := strconv.Atoi(c.Labels[LabelNumber])
+	return n
+}
+
+// ContainerDetails holds the parts of an inspected container that muss uses.
+type ContainerDetails struct {
+	ID    string `json:"Id"`
+	State struct {
+		Status   string `json:"Status"`
+		Running  bool   `json:"Running"`
+		ExitCode int    `json:"ExitCode"`
+		Health   *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// Health returns the status of the container's healthcheck
+// ("starting", "healthy" or "unhealthy") or "" if it doesn't have one.
+func (d *ContainerDetails) Health() string {
+	if d.State.Health == nil {
+		return ""
+	}
+	return d.State.Health.Status
+}
+
+// Filter selects the containers of a compose project.
+type Filter struct {
+	Project string
+	// Service (if not empty) limits the containers to one service.
+	Service string
+	// Number (if not zero) selects one container of the service.
+	Number int
+	// Running excludes stopped containers.
+	Running bool
+}
+
+func (f Filter) query() string {
+	labels := []string{LabelProject + "=" + f.Project, LabelOneOff + "=False"}
+	if f.Service != "" {
+		labels = append(labels, LabelService+"="+f.Service)
+	}
+	if f.Number != 0 {
+		labels = append(labels, fmt.Sprintf("%s=%d", LabelNumber, f.Number))
+	}
+	filters := map[string][]string{"label": labels}
+	if f.Running {
+		filters["status"] = []string{"running"}
+	}
+	// Encoding a map of string slices can't fail.
+	encoded, _ := json.Marshal(filters)
+
+	values := url.Values{}
+	values.Set("filters", string(encoded))
+	if !f.Running {
+		values.Set("all", "1")
+	}
+	return values.Encode()
+}
+
+// Client talks to the engine over a unix socket.
+type Client struct {
+	Socket string
+	http   *http.Client
+}
+
+// NewClient returns a Client for the socket.
+func NewClient(socket string) *Client {
+	return &Client{
+		Socket: socket,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// DefaultSocket returns the socket for the container command ("docker" or
+// "podman"), respecting DOCKER_HOST.
+// It returns "" if the engine isn't on a local socket.
+func DefaultSocket(container string) string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		if strings.HasPrefix(host, "unix://") {
+			return strings.TrimPrefix(host, "unix://")
+		}
+		return ""
+	}
+	if container == "podman" {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Geteuid() != 0 {
+			return path.Join(dir, "podman", "podman.sock")
+		}
+		return "/run/podman/podman.sock"
+	}
+	return "/var/run/docker.sock"
+}
+
+// Containers returns the containers that match the filter
+// sorted by service and number.
+func (c *Client) Containers(f Filter) ([]Container, error) {
+	var containers []Container
+	if err := c.get("/containers/json?"+f.query(), &containers); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		a, b := &containers[i], &containers[j]
+		if a.Service() != b.Service() {
+			return a.Service() < b.Service()
+		}
+		return a.Number() < b.Number()
+	})
+	return containers, nil
+}
+
+// FindContainer returns the numbered container of the service
+// (or nil if there isn't one).
+func (c *Client) FindContainer(project, service string, number int) (*Container, error) {
+	containers, err := c.Containers(Filter{Project: project, Service: service, Number: number})
+	if err != nil || len(containers) == 0 {
+		return nil, err
+	}
+	return &containers[0], nil
+}
+
+// Inspect returns the details of the container.
+func (c *Client) Inspect(id string) (*ContainerDetails, error) {
+	var details ContainerDetails
+	if err := c.get("/containers/"+url.PathEscape(id)+"/json", &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+func (c *Client) get(uri string, result interface{}) error {
+	if c.Socket == "" {
+		return ErrUnavailable
+	}
+	resp, err := c.http.Get("http://engine" + uri)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return fmt.Errorf("%w: %s", ErrUnavailable, opErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		return fmt.Errorf("engine error (%d): %s", resp.StatusCode, apiErr.Message)
+	}
+	return json.Unmarshal(body, result)
+}
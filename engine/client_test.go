@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.instructure.com/muss/testutil"
+)
+
+func containerIDs(containers []Container) []string {
+	ids := make([]string, len(containers))
+	for i, c := range containers {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestClient(t *testing.T) {
+	socket, stop := testutil.FakeEngine(t, []testutil.FakeContainer{
+		{ID: "web2", Project: "proj", Service: "web", Number: 2},
+		{ID: "web1", Project: "proj", Service: "web", Number: 1},
+		{ID: "db1", Project: "proj", Service: "db", Number: 1, State: "exited", Health: "healthy"},
+		{ID: "run1", Project: "proj", Service: "web", Number: 1, OneOff: true},
+		{ID: "other1", Project: "other", Service: "web", Number: 1},
+	})
+	defer stop()
+
+	client := NewClient(socket)
+
+	t.Run("containers", func(t *testing.T) {
+		containers, err := client.Containers(Filter{Project: "proj"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"db1", "web1", "web2"}, containerIDs(containers), "sorted, no one-off")
+		assert.Equal(t, "web", containers[1].Service())
+		assert.Equal(t, 1, containers[1].Number())
+
+		containers, err = client.Containers(Filter{Project: "proj", Running: true})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"web1", "web2"}, containerIDs(containers), "running")
+
+		containers, err = client.Containers(Filter{Project: "proj", Service: "web"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"web1", "web2"}, containerIDs(containers), "service")
+	})
+
+	t.Run("find", func(t *testing.T) {
+		c, err := client.FindContainer("proj", "web", 2)
+		assert.Nil(t, err)
+		assert.Equal(t, "web2", c.ID)
+
+		c, err = client.FindContainer("proj", "web", 3)
+		assert.Nil(t, err)
+		assert.Nil(t, c)
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		details, err := client.Inspect("web1")
+		assert.Nil(t, err)
+		assert.True(t, details.State.Running)
+		assert.Equal(t, "", details.Health())
+
+		details, err = client.Inspect("db1")
+		assert.Nil(t, err)
+		assert.False(t, details.State.Running)
+		assert.Equal(t, "exited", details.State.Status)
+		assert.Equal(t, "healthy", details.Health())
+
+		_, err = client.Inspect("nope")
+		assert.Equal(t, "engine error (404): No such container: nope", err.Error())
+	})
+
+	t.Run("api errors", func(t *testing.T) {
+		var result interface{}
+		err := client.get("/nothing", &result)
+		assert.Equal(t, "engine error (404): page not found", err.Error())
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		_, err := NewClient(path.Join(os.TempDir(), "muss-no-such.sock")).Containers(Filter{Project: "proj"})
+		assert.True(t, errors.Is(err, ErrUnavailable), "dial error")
+
+		_, err = NewClient("").Containers(Filter{Project: "proj"})
+		assert.True(t, errors.Is(err, ErrUnavailable), "no socket")
+	})
+}
+
+func TestDefaultSocket(t *testing.T) {
+	orig, set := os.LookupEnv("DOCKER_HOST")
+	defer func() {
+		if set {
+			os.Setenv("DOCKER_HOST", orig)
+		} else {
+			os.Unsetenv("DOCKER_HOST")
+		}
+	}()
+
+	os.Unsetenv("DOCKER_HOST")
+	assert.Equal(t, "/var/run/docker.sock", DefaultSocket("docker"))
+
+	os.Setenv("DOCKER_HOST", "unix:///tmp/docker.sock")
+	assert.Equal(t, "/tmp/docker.sock", DefaultSocket("docker"))
+	assert.Equal(t, "/tmp/docker.sock", DefaultSocket("podman"))
+
+	os.Setenv("DOCKER_HOST", "tcp://127.0.0.1:2375")
+	assert.Equal(t, "", DefaultSocket("docker"))
+}
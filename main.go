@@ -5,6 +5,7 @@ import (
 
 	"gerrit.instructure.com/muss/cmd"
 	_ "gerrit.instructure.com/muss/cmd/config"
+	_ "gerrit.instructure.com/muss/cmd/secrets"
 	"gerrit.instructure.com/muss/proc"
 )
 
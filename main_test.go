@@ -23,4 +23,5 @@ func TestMain(t *testing.T) {
 	// Prove that "main" loads all the subcommand packages.
 	assertHasSubCommand(t, "config")
 	assertHasSubCommand(t, "config", "show")
+	assertHasSubCommand(t, "secrets", "list")
 }
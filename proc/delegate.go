@@ -4,6 +4,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"syscall"
+	"time"
 )
 
 // StreamFilter will be called at command delegation to allow filtering output streams.
@@ -21,41 +23,101 @@ type Delegator struct {
 	Stderr   io.Writer
 	DoneCh   chan bool
 	SignalCh chan os.Signal
+	// FailFast will signal the remaining commands when one fails.
+	FailFast bool
+	// GracePeriod is how long to wait for the commands to exit after they
+	// are signaled before sending SIGTERM (and then again before SIGKILL).
+	// Zero waits until they exit (or until Ctrl-C is pressed again).
+	GracePeriod time.Duration
+	// Messages receives notices about shutting down (defaults to Stderr).
+	Messages io.Writer
+	// Signals to forward to the commands (defaults to DefaultSignals).
+	Signals []os.Signal
+	// ProcessGroup starts each command in its own process group
+	// and forwards signals to the whole group (including SIGINT which the
+	// terminal will no longer send to them).
+	// Commands in their own group cannot read from the terminal.
+	ProcessGroup bool
+	// UsePTY gives the commands a pseudo-terminal for stdout/stderr when they
+	// are filtered but will end up on a terminal (so that the commands will
+	// still use colors, progress bars, etc).
+	UsePTY bool
+	// Results holds the outcome of each command after Delegate returns.
+	Results []*Result
 
-	stdoutFilter StreamFilter
-	stderrFilter StreamFilter
+	stdoutFilters []filterPipe
+	stderrFilters []filterPipe
+	ptys          []*ptyStream
+}
+
+// filterPipe holds a StreamFilter and the writer that feeds it
+// so that the writer can be closed when the command is done
+// and the writer that it outputs to.
+type filterPipe struct {
+	StreamFilter
+	writer io.WriteCloser
+	output io.Writer
 }
 
 // FilterStdout applies a StreamFilter to stdout.
+// It can be called multiple times: the filter added last will receive the
+// output of the command first and each filter writes to the one before it.
 func (d *Delegator) FilterStdout(f StreamFilter) error {
-	pr, pw, err := os.Pipe()
+	pw, err := pipeFilter(f, d.Stdout)
 	if err != nil {
 		return err
 	}
 
-	f.SetReader(pr)
-	f.SetWriter(d.Stdout)
+	d.stdoutFilters = append(d.stdoutFilters, filterPipe{f, pw, d.Stdout})
 	d.Stdout = pw
-	d.stdoutFilter = f
 
 	return nil
 }
 
 // FilterStderr applies a StreamFilter to stderr.
+// It can be called multiple times (see FilterStdout).
 func (d *Delegator) FilterStderr(f StreamFilter) error {
-	pr, pw, err := os.Pipe()
+	pw, err := pipeFilter(f, d.Stderr)
 	if err != nil {
 		return err
 	}
 
-	f.SetReader(pr)
-	f.SetWriter(d.Stderr)
+	d.stderrFilters = append(d.stderrFilters, filterPipe{f, pw, d.Stderr})
 	d.Stderr = pw
-	d.stderrFilter = f
 
 	return nil
 }
 
+func pipeFilter(f StreamFilter, w io.Writer) (io.WriteCloser, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	f.SetReader(pr)
+	f.SetWriter(w)
+
+	return pw, nil
+}
+
+func startFilters(filters []filterPipe, done chan bool) {
+	for _, f := range filters {
+		f.Start(done)
+	}
+}
+
+// stopFilters closes each pipe and waits for its filter to finish starting
+// with the one closest to the command so that each filter has received all of
+// its input before it is stopped.
+// It returns the first error reported by a filter.
+func stopFilters(filters []filterPipe) error {
+	for i := len(filters) - 1; i >= 0; i-- {
+		filters[i].writer.Close()
+		filters[i].Stop()
+	}
+	return filterErr(filters)
+}
+
 // Delegate runs with a Delegator made from `os.Std*`.
 func Delegate(commands ...*exec.Cmd) (err error) {
 	return (&Delegator{
@@ -68,7 +130,12 @@ func Delegate(commands ...*exec.Cmd) (err error) {
 // Delegate runs the provided commands
 // forwarding stdio and signals
 // and waits for them to finish.
+// When running a single command its error is returned as is;
+// with multiple commands any failures are returned as a *DelegateError.
+// The Results of each command are available on the Delegator afterwards.
 func (d *Delegator) Delegate(commands ...*exec.Cmd) (err error) {
+	d.Results = make([]*Result, len(commands))
+
 	// Nothing to do
 	if len(commands) == 0 {
 		return nil
@@ -76,48 +143,121 @@ func (d *Delegator) Delegate(commands ...*exec.Cmd) (err error) {
 
 	d.DoneCh = make(chan bool, 1)
 
-	if d.stdoutFilter != nil {
-		d.stdoutFilter.Start(d.DoneCh)
-		defer func() {
-			if f, ok := d.Stdout.(io.WriteCloser); ok {
-				f.Close()
-			}
-			d.stdoutFilter.Stop()
-		}()
+	// Any error from the commands is more important than one from a filter.
+	startFilters(d.stdoutFilters, d.DoneCh)
+	defer func() {
+		if filterErr := stopFilters(d.stdoutFilters); err == nil {
+			err = filterErr
+		}
+	}()
+
+	startFilters(d.stderrFilters, d.DoneCh)
+	defer func() {
+		if filterErr := stopFilters(d.stderrFilters); err == nil {
+			err = filterErr
+		}
+	}()
+
+	type cmdResult struct {
+		index int
+		err   error
 	}
+	startch := make(chan int, len(commands))
+	cmdch := make(chan cmdResult, len(commands))
+	started := make([]time.Time, len(commands))
+	running := make([]bool, len(commands))
 
-	if d.stderrFilter != nil {
-		d.stderrFilter.Start(d.DoneCh)
-		defer func() {
-			if f, ok := d.Stderr.(io.WriteCloser); ok {
-				f.Close()
-			}
-			d.stderrFilter.Stop()
-		}()
+	var stdoutTerminal, stderrTerminal *os.File
+	if d.UsePTY {
+		stdoutTerminal = terminalFor(d.stdoutFilters)
+		stderrTerminal = terminalFor(d.stderrFilters)
 	}
+	d.ptys = nil
+	defer d.closePTYs()
 
-	cmdch := make(chan error, len(commands))
-	for _, cmd := range commands {
+	for i, cmd := range commands {
 		cmd.Stdin = d.Stdin
 		cmd.Stdout = d.Stdout
 		cmd.Stderr = d.Stderr
+		if d.ProcessGroup {
+			setProcessGroup(cmd)
+		}
 
-		go func(cmd *exec.Cmd) {
-			cmdErr := cmd.Run()
-			cmdch <- cmdErr
-		}(cmd)
+		// If a pty can't be opened just use the pipe.
+		var ptys []*ptyStream
+		if stdoutTerminal != nil {
+			if p, err := newPTYStream(stdoutTerminal, d.Stdout); err == nil {
+				cmd.Stdout = p.slave
+				ptys = append(ptys, p)
+			}
+		}
+		if stderrTerminal != nil {
+			if p, err := newPTYStream(stderrTerminal, d.Stderr); err == nil {
+				cmd.Stderr = p.slave
+				ptys = append(ptys, p)
+			}
+		}
+		d.ptys = append(d.ptys, ptys...)
+
+		started[i] = time.Now()
+		go func(i int, cmd *exec.Cmd, ptys []*ptyStream) {
+			err := cmd.Start()
+			for _, p := range ptys {
+				p.closeSlave()
+			}
+			if err != nil {
+				cmdch <- cmdResult{i, err}
+				return
+			}
+			// Let the main loop know the process can be signaled.
+			startch <- i
+			err = cmd.Wait()
+			// Wait for all of the output before saying the command is done.
+			for _, p := range ptys {
+				p.wait()
+			}
+			cmdch <- cmdResult{i, err}
+		}(i, cmd, ptys)
 	}
 
 	if d.SignalCh == nil {
 		d.SignalCh = make(chan os.Signal, 1)
 	}
-	setupSignals(d.SignalCh)
+	signals := d.Signals
+	if signals == nil {
+		signals = DefaultSignals
+	}
+	setupSignals(d.SignalCh, signals)
 	defer restoreSignals(d.SignalCh)
 	finished := 0
+	var first *Result
+	canceled := false
+
+	messages := d.Messages
+	if messages == nil {
+		messages = d.Stderr
+	}
+	stopping := &shutdown{grace: d.GracePeriod, messages: messages, send: d.signal}
+	defer stopping.stop()
+
+	waiting := func() []*exec.Cmd {
+		cmds := make([]*exec.Cmd, 0, len(commands))
+		for i, cmd := range commands {
+			if running[i] {
+				cmds = append(cmds, cmd)
+			}
+		}
+		return cmds
+	}
 
 	for {
 		select {
 		case sig := <-d.SignalCh:
+			if !terminating(sig) {
+				d.forward(sig, waiting())
+				continue
+			}
+
 			// Let listening go routines know that we are going to exit.
 			if d.DoneCh != nil {
 				close(d.DoneCh)
@@ -125,32 +265,105 @@ func (d *Delegator) Delegate(commands ...*exec.Cmd) (err error) {
 				d.DoneCh = nil
 			}
 
-			// Don't forward SIGINT since ctrl-c will go to the process group.
-			// Sending again would double the number of signals the child receives.
-			if sig == os.Interrupt {
+			// Pressing Ctrl-C again means stop waiting.
+			if sig == os.Interrupt && stopping.stage != shutdownNone {
+				stopping.escalate(waiting())
 				continue
 			}
 
-			for _, cmd := range commands {
-				cmd.Process.Signal(sig)
+			// Don't forward SIGINT (or SIGQUIT) when the commands share our
+			// process group since the terminal will have sent it to them.
+			// Sending again would double the number of signals the child receives.
+			if d.ProcessGroup || !fromTerminal(sig) {
+				d.forward(sig, waiting())
 			}
+			stopping.begin(waiting())
 
 			// Go back to the loop and wait for the commands to finish.
 			continue
 
-		case cmdErr := <-cmdch:
+		case <-stopping.timeout():
+			stopping.escalate(waiting())
+
+		case i := <-startch:
+			// It may have already finished (the channels aren't ordered).
+			if d.Results[i] != nil {
+				continue
+			}
+			running[i] = true
+
+			// If another command has already failed stop this one too.
+			if canceled {
+				d.signal(commands[i], syscall.SIGTERM)
+			}
+
+		case res := <-cmdch:
 			finished++
+			running[res.index] = false
+
+			result := newResult(commands[res.index], res.err, time.Since(started[res.index]))
+			result.Canceled = canceled && res.err != nil
+			d.Results[res.index] = result
+
+			if result.Failed() && first == nil {
+				first = result
 
-			// So far we only delegate to one command at time.
-			// If we ever do multiple we might want to return a value
-			// that can link the error to the command it came from.
-			if cmdErr != nil && err == nil {
-				err = cmdErr
+				// Stop the others rather than waiting for them.
+				if d.FailFast {
+					canceled = true
+					stopping.escalate(waiting())
+				}
 			}
 
 			if finished == len(commands) {
-				return
+				if first == nil {
+					return nil
+				}
+				if len(commands) == 1 {
+					return first.Err
+				}
+				return &DelegateError{Results: d.Results, first: first}
 			}
 		}
 	}
 }
+
+// suspendSelf can be replaced in tests.
+var suspendSelf = suspend
+
+// signal sends the signal to the command (or its process group).
+func (d *Delegator) signal(cmd *exec.Cmd, sig os.Signal) {
+	if d.ProcessGroup {
+		signalGroup(cmd.Process, sig)
+		return
+	}
+	cmd.Process.Signal(sig)
+}
+
+// forward sends a signal (that doesn't end the commands) along.
+// When asked to suspend (with Ctrl-Z) the commands are stopped and then so
+// are we so that the shell regains control; once continued (by "fg")
+// the commands are resumed.
+func (d *Delegator) forward(sig os.Signal, cmds []*exec.Cmd) {
+	for _, cmd := range cmds {
+		d.signal(cmd, sig)
+	}
+	if sig == resizeSignal {
+		for _, p := range d.ptys {
+			p.resize()
+		}
+	}
+	if isSuspend(sig) {
+		suspendSelf()
+		for _, cmd := range cmds {
+			d.signal(cmd, resumeSignal)
+		}
+	}
+}
+
+func (d *Delegator) closePTYs() {
+	for _, p := range d.ptys {
+		p.close()
+	}
+	d.ptys = nil
+}
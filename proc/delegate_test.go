@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -66,6 +67,237 @@ func TestDelegator(t *testing.T) {
 		assert.Equal(t, "out\none\n", testutil.ReadFile(t, stdout.Name()))
 	})
 
+	t.Run("results", func(t *testing.T) {
+		// Use a file since multiple commands will write to it.
+		stdout := testutil.TempFile(t, "", "muss-proc-out")
+		defer os.Remove(stdout.Name())
+
+		d := &Delegator{
+			Stdout: stdout,
+		}
+
+		err := d.Delegate(
+			exec.Command("/bin/sh", "-c", "sleep 0.5; exit 3"),
+			exec.Command("/bin/sh", "-c", "sleep 1; echo ok"),
+			exec.Command("muss-no-such-command"),
+		)
+
+		assert.Equal(t, "ok\n", testutil.ReadFile(t, stdout.Name()))
+
+		if assert.Len(t, d.Results, 3) {
+			assert.Equal(t, []string{"/bin/sh", "-c", "sleep 0.5; exit 3"}, d.Results[0].Args)
+			assert.Equal(t, 3, d.Results[0].ExitCode)
+			assert.True(t, d.Results[0].Failed())
+
+			assert.Equal(t, 0, d.Results[1].ExitCode)
+			assert.False(t, d.Results[1].Failed())
+			assert.True(t, d.Results[1].Duration >= time.Second, "duration")
+
+			assert.Equal(t, -1, d.Results[2].ExitCode, "did not start")
+			assert.True(t, d.Results[2].Failed())
+		}
+
+		delegateErr, ok := err.(*DelegateError)
+		if !ok {
+			t.Fatalf("expected DelegateError, got %#v", err)
+		}
+		assert.Len(t, delegateErr.Failed(), 2)
+		assert.Equal(t, 1, delegateErr.ExitCode(), "first failure did not exit on its own")
+		assert.Contains(t, delegateErr.Error(), "2 of 3 commands failed: ")
+		assert.Contains(t, delegateErr.Error(), `/bin/sh -c "sleep 0.5; exit 3": exit status 3`)
+		assert.False(t, d.Results[0].Canceled, "no fail fast")
+
+		err = d.Delegate(exec.Command("/bin/sh", "-c", "exit 2"))
+		assert.IsType(t, &exec.ExitError{}, err, "single command returns its own error")
+		assert.Equal(t, 2, d.Results[0].ExitCode)
+
+		err = d.Delegate(exec.Command("true"), exec.Command("true"))
+		assert.Nil(t, err)
+		assert.Len(t, d.Results, 2)
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		stdout := testutil.TempFile(t, "", "muss-proc-out")
+		defer os.Remove(stdout.Name())
+
+		d := &Delegator{
+			Stdout:   stdout,
+			FailFast: true,
+		}
+
+		start := time.Now()
+		err := d.Delegate(
+			exec.Command("/bin/sh", "-c", "trap 'echo TERM; kill $!; exit 1' TERM; sleep 5 & wait"),
+			exec.Command("/bin/sh", "-c", "sleep 1; exit 5"),
+		)
+
+		assert.True(t, time.Since(start) < 4*time.Second, "did not wait")
+		assert.Equal(t, "TERM\n", testutil.ReadFile(t, stdout.Name()))
+		if assert.IsType(t, &DelegateError{}, err) {
+			assert.Equal(t, 5, err.(*DelegateError).ExitCode())
+		}
+		assert.True(t, d.Results[0].Canceled)
+		assert.False(t, d.Results[1].Canceled)
+	})
+
+	t.Run("grace period", func(t *testing.T) {
+		var stdout, messages bytes.Buffer
+		d := &Delegator{
+			Stdout:      &stdout,
+			Messages:    &messages,
+			GracePeriod: 500 * time.Millisecond,
+		}
+		d.SignalCh = make(chan os.Signal, 1)
+		script := `trap 'echo HUP' HUP; trap 'echo TERM' TERM; while :; do sleep 0.1; done`
+
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			d.SignalCh <- syscall.SIGHUP
+		}()
+
+		start := time.Now()
+		err := d.Delegate(exec.Command("/bin/sh", "-c", script))
+
+		assert.True(t, time.Since(start) < 3*time.Second, "escalated")
+		assert.Equal(t, "HUP\nTERM\n", stdout.String(), "forwards, then terminates, then kills")
+		assert.Equal(t, "signal: killed", err.Error())
+
+		lines := strings.Split(strings.TrimSpace(messages.String()), "\n")
+		if assert.Len(t, lines, 3, messages.String()) {
+			assert.Regexp(t, `^muss: Waiting up to 500ms for "sh -c trap .+\.\.\." \(pid \d+\) to exit \(press Ctrl-C again to stop now\)\.\.\.$`, lines[0])
+			assert.Regexp(t, `^muss: Sending SIGTERM to "sh -c .+" \(pid \d+\)\.\.\.$`, lines[1])
+			assert.Regexp(t, `^muss: Killing "sh -c .+" \(pid \d+\)\.\.\.$`, lines[2])
+		}
+	})
+
+	t.Run("second interrupt", func(t *testing.T) {
+		var stdout, messages bytes.Buffer
+		d := &Delegator{
+			Stdout:   &stdout,
+			Messages: &messages,
+		}
+		d.SignalCh = make(chan os.Signal, 1)
+		script := `trap 'echo TERM; exit 3' TERM; while :; do sleep 0.1; done`
+
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			d.SignalCh <- os.Interrupt
+			time.Sleep(500 * time.Millisecond)
+			d.SignalCh <- os.Interrupt
+		}()
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", script))
+
+		assert.Equal(t, "TERM\n", stdout.String())
+		assert.Equal(t, "exit status 3", err.Error())
+		lines := strings.Split(strings.TrimSpace(messages.String()), "\n")
+		if assert.Len(t, lines, 2, messages.String()) {
+			assert.Regexp(t, `^muss: Waiting for "sh -c trap .+\.\.\." \(pid \d+\) to exit \(press Ctrl-C again to stop now\)\.\.\.$`, lines[0], "no time limit")
+			assert.Regexp(t, `^muss: Sending SIGTERM to "sh -c .+" \(pid \d+\)\.\.\.$`, lines[1])
+		}
+	})
+
+	t.Run("forward signals", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		d.SignalCh = make(chan os.Signal, 1)
+		script := `trap 'echo USR1' USR1; trap 'echo WINCH' WINCH; trap 'echo TERM; exit 0' TERM; while :; do sleep 0.1; done`
+
+		go func() {
+			for _, sig := range []os.Signal{syscall.SIGUSR1, syscall.SIGWINCH, syscall.SIGTERM} {
+				time.Sleep(500 * time.Millisecond)
+				d.SignalCh <- sig
+			}
+		}()
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", script))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "USR1\nWINCH\nTERM\n", stdout.String())
+	})
+
+	t.Run("configured signals", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout:  &stdout,
+			Signals: []os.Signal{syscall.SIGTERM, syscall.SIGUSR1},
+		}
+		script := `trap 'echo USR1' USR1; trap 'echo WINCH' WINCH; trap 'echo TERM; exit 0' TERM; while :; do sleep 0.1; done`
+
+		go func() {
+			for _, sig := range []syscall.Signal{syscall.SIGWINCH, syscall.SIGUSR1, syscall.SIGTERM} {
+				time.Sleep(500 * time.Millisecond)
+				syscall.Kill(os.Getpid(), sig)
+			}
+		}()
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", script))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "USR1\nTERM\n", stdout.String(), "WINCH not forwarded")
+	})
+
+	t.Run("process group", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout:       &stdout,
+			ProcessGroup: true,
+		}
+		d.SignalCh = make(chan os.Signal, 1)
+
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			d.SignalCh <- syscall.SIGTERM
+		}()
+
+		start := time.Now()
+		err := d.Delegate(exec.Command("/bin/sh", "-c", "sleep 5 & wait; echo done"))
+
+		assert.True(t, time.Since(start) < 3*time.Second, "background process signaled")
+		assert.Equal(t, "signal: terminated", err.Error())
+		assert.Equal(t, "", stdout.String())
+
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			d.SignalCh <- os.Interrupt
+		}()
+
+		err = d.Delegate(exec.Command("/bin/sh", "-c", "trap 'echo INT; exit 2' INT; while :; do sleep 0.1; done"))
+
+		assert.Equal(t, "exit status 2", err.Error())
+		assert.Equal(t, "INT\n", stdout.String(), "interrupt forwarded to group")
+	})
+
+	t.Run("suspend", func(t *testing.T) {
+		suspended := 0
+		suspendSelf = func() {
+			suspended++
+			time.Sleep(time.Second)
+		}
+		defer func() { suspendSelf = suspend }()
+
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		d.SignalCh = make(chan os.Signal, 1)
+
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			d.SignalCh <- syscall.SIGTSTP
+		}()
+
+		start := time.Now()
+		err := d.Delegate(exec.Command("/bin/sh", "-c", "i=0; while [ $i -lt 5 ]; do sleep 0.1; i=$((i+1)); done; echo done"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "done\n", stdout.String(), "continued")
+		assert.Equal(t, 1, suspended)
+		assert.True(t, time.Since(start) >= 1200*time.Millisecond, "stopped while suspended")
+	})
+
 	t.Run("stream filter", func(t *testing.T) {
 		var stdout, stderr bytes.Buffer
 		d := &Delegator{
@@ -88,6 +320,26 @@ func TestDelegator(t *testing.T) {
 		assert.Equal(t, []string{"A", "B"}, fout.(*testFilter).messages)
 	})
 
+	t.Run("multiple stream filters", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		outer := newTestFilter()
+		inner := newTestFilter()
+		d.FilterStdout(outer)
+		d.FilterStdout(inner)
+
+		d.Delegate(
+			exec.Command("/bin/sh", "-c", "echo A; echo B"),
+		)
+
+		assert.Equal(t, "1 1 A\n2 2 B\n3 done\ndone\n", stdout.String(), "last filter is closest to the command")
+
+		assert.Equal(t, []string{"A", "B"}, inner.(*testFilter).messages)
+		assert.Equal(t, []string{"1 A", "2 B", "done"}, outer.(*testFilter).messages)
+	})
+
 	t.Run("signals", func(t *testing.T) {
 		var stdout bytes.Buffer
 		d := Delegator{
@@ -121,39 +373,22 @@ func TestDelegator(t *testing.T) {
 }
 
 type testFilter struct {
-	*Pipe
-	messages     []string
-	readerDoneCh chan bool
+	*LineFilter
+	messages []string
 }
 
 func newTestFilter() StreamFilter {
-	return &testFilter{
-		Pipe:         &Pipe{},
-		readerDoneCh: make(chan bool),
-	}
-}
-
-func (f *testFilter) Stop() {
-	<-f.readerDoneCh
-	f.Writer().Write([]byte("done\n"))
-}
-
-func (f *testFilter) Start(done chan bool) {
-	reader := f.Reader()
-	writer := f.Writer()
-
+	f := &testFilter{}
 	i := 0
-	go func() {
-		if f, ok := reader.(io.ReadCloser); ok {
-			defer f.Close()
-		}
-		scanner := bufio.NewScanner(reader)
-		for scanner.Scan() {
-			line := scanner.Bytes()
-			i = i + 1
-			writer.Write([]byte(fmt.Sprintf("%d %s\n", i, line)))
-			f.messages = append(f.messages, string(line))
-		}
-		f.readerDoneCh <- true
-	}()
+	f.LineFilter = NewLineFilter(func(line []byte) ([]byte, error) {
+		i = i + 1
+		f.messages = append(f.messages, string(line))
+		return []byte(fmt.Sprintf("%d %s\n", i, line)), nil
+	})
+	f.Split = bufio.ScanLines
+	f.OnStop = func(w io.Writer) error {
+		_, err := w.Write([]byte("done\n"))
+		return err
+	}
+	return f
 }
@@ -0,0 +1,154 @@
+package proc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"gerrit.instructure.com/muss/term"
+)
+
+// maxTokenSize is the longest line (or token) that a LineFilter will pass to
+// its funcs; longer ones will be passed in pieces.
+const maxTokenSize = 1024 * 1024
+
+// LineFunc transforms a line (or token) of a stream.
+// It can return the line unchanged, a modified copy, or nil to drop it.
+// If it returns an error (along with any output for the line) the rest of the
+// stream is passed through unchanged and the error is returned from Delegate.
+type LineFunc func(line []byte) ([]byte, error)
+
+// LineFilter is a StreamFilter that passes each line of the stream
+// through a series of LineFuncs.
+type LineFilter struct {
+	*Pipe
+	// Split breaks the stream into tokens.  It defaults to lines
+	// (or ansi movements) that include their terminators.
+	Split bufio.SplitFunc
+	// OnStart is called before the stream is read with the channel that
+	// will be closed when muss is exiting and the writer.
+	OnStart func(done chan bool, w io.Writer)
+	// OnStop is called with the writer after the stream has ended.
+	OnStop func(w io.Writer) error
+
+	funcs []LineFunc
+	// cut (if set) returns where to break a token that is too long for the
+	// buffer (so that the funcs don't miss anything that crosses it);
+	// by default all of the buffer is passed.
+	cut          func(data []byte) int
+	err          error
+	readerDoneCh chan bool
+}
+
+// NewLineFilter returns a LineFilter that calls the funcs in order
+// for each line (the output of one is passed to the next).
+func NewLineFilter(funcs ...LineFunc) *LineFilter {
+	return &LineFilter{
+		Pipe:         &Pipe{},
+		Split:        term.ScanLinesOrAnsiMovements,
+		funcs:        funcs,
+		readerDoneCh: make(chan bool, 1),
+	}
+}
+
+// Start reads the stream in a go routine.
+func (f *LineFilter) Start(done chan bool) {
+	reader := f.Reader()
+	writer := f.Writer()
+
+	if f.OnStart != nil {
+		f.OnStart(done, writer)
+	}
+
+	go func() {
+		if r, ok := reader.(io.ReadCloser); ok {
+			defer r.Close()
+		}
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxTokenSize)
+		scanner.Split(splitWithLimit(f.Split, f.cut))
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			// After an error pass everything through unchanged.
+			if f.err == nil {
+				line, f.err = f.filter(line)
+			}
+			if len(line) > 0 {
+				writer.Write(line)
+			}
+		}
+		if f.err == nil {
+			f.err = scanner.Err()
+		}
+		f.readerDoneCh <- true
+	}()
+}
+
+// splitWithLimit returns tokens that are too long for the scanner's buffer
+// in pieces (broken where cut says, if not nil) rather than failing.
+func splitWithLimit(split bufio.SplitFunc, cut func([]byte) int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) >= maxTokenSize {
+			n := len(data)
+			if cut != nil {
+				n = cut(data)
+			}
+			return n, data[:n], nil
+		}
+		return advance, token, err
+	}
+}
+
+func (f *LineFilter) filter(line []byte) ([]byte, error) {
+	for _, fn := range f.funcs {
+		var err error
+		if line, err = fn(line); err != nil || line == nil {
+			return line, err
+		}
+	}
+	return line, nil
+}
+
+// Stop waits for the stream to end and then calls OnStop.
+func (f *LineFilter) Stop() {
+	<-f.readerDoneCh
+
+	if f.OnStop != nil {
+		if err := f.OnStop(f.Writer()); err != nil && f.err == nil {
+			f.err = err
+		}
+	}
+}
+
+// Err returns the first error from the filter (after it has stopped).
+func (f *LineFilter) Err() error {
+	return f.err
+}
+
+// FilterError is returned from Delegate when a filter fails
+// (and the commands did not).
+type FilterError struct {
+	Err error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("output filter failed: %s", e.Err)
+}
+
+// Unwrap returns the error from the filter.
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// filterErr returns the first error from the filters that report them.
+func filterErr(filters []filterPipe) error {
+	for _, f := range filters {
+		if ef, ok := f.StreamFilter.(interface{ Err() error }); ok {
+			if err := ef.Err(); err != nil {
+				return &FilterError{err}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package proc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineFilter(t *testing.T) {
+	upper := func(line []byte) ([]byte, error) {
+		return bytes.ToUpper(line), nil
+	}
+	dropB := func(line []byte) ([]byte, error) {
+		if bytes.HasPrefix(line, []byte("B")) {
+			return nil, nil
+		}
+		return line, nil
+	}
+
+	t.Run("funcs in order", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		d.FilterStdout(NewLineFilter(upper, dropB))
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", "echo a; echo b; printf c"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "A\nC", stdout.String(), "lines keep endings, dropped after upper")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		f := NewLineFilter(upper)
+		f.Split = bufio.ScanWords
+		f.OnStart = func(done chan bool, w io.Writer) {
+			w.Write([]byte("start:"))
+		}
+		f.OnStop = func(w io.Writer) error {
+			_, err := w.Write([]byte(":stop"))
+			return err
+		}
+		d.FilterStdout(f)
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", "echo a b; echo c"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "start:ABC:stop", stdout.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		failOnB := func(line []byte) ([]byte, error) {
+			if bytes.HasPrefix(line, []byte("b")) {
+				return []byte("?\n"), errors.New("bad b")
+			}
+			return line, nil
+		}
+		d.FilterStdout(NewLineFilter(failOnB, upper))
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", "echo a; echo b; echo c"))
+
+		assert.Equal(t, "A\n?\nc\n", stdout.String(), "rest passed through")
+		if assert.IsType(t, &FilterError{}, err) {
+			assert.Equal(t, "output filter failed: bad b", err.Error())
+		}
+
+		stdout.Reset()
+		d = &Delegator{
+			Stdout: &stdout,
+		}
+		d.FilterStdout(NewLineFilter(failOnB))
+
+		err = d.Delegate(exec.Command("/bin/sh", "-c", "echo b; exit 2"))
+
+		assert.Equal(t, "exit status 2", err.Error(), "command error first")
+	})
+
+	t.Run("long lines", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+		}
+		d.FilterStdout(NewLineFilter(upper))
+
+		long := strings.Repeat("x", maxTokenSize+10)
+		err := d.Delegate(exec.Command("/bin/sh", "-c", fmt.Sprintf("echo a; head -c %d /dev/zero | tr '\\0' x; echo; echo b", len(long))))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "A\n"+strings.ToUpper(long)+"\nB\n", stdout.String(), "filtered in pieces")
+	})
+}
@@ -0,0 +1,94 @@
+package proc
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// ptyStream gives a command a pseudo-terminal for an output stream
+// (so that it will still use colors, progress bars, etc)
+// and copies what it writes to the stream's writer (like a pipe would).
+type ptyStream struct {
+	master   *os.File
+	slave    *os.File
+	terminal *os.File
+	done     chan struct{}
+}
+
+// newPTYStream opens a pty with the same size as the terminal
+// and starts copying its output to the writer.
+func newPTYStream(terminal *os.File, w io.Writer) (*ptyStream, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	p := &ptyStream{
+		master:   master,
+		slave:    slave,
+		terminal: terminal,
+		done:     make(chan struct{}),
+	}
+	if err := rawOutput(slave); err != nil {
+		p.closeSlave()
+		p.close()
+		return nil, err
+	}
+	// A size isn't essential (if the terminal doesn't have one).
+	p.resize()
+
+	go func() {
+		io.Copy(w, ptyReader{master})
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// resize updates the pty to match the size of the terminal.
+func (p *ptyStream) resize() error {
+	return copyWinsize(p.terminal, p.master)
+}
+
+// closeSlave should be called once the command has started (so that the
+// master will get EOF when the command is done).
+func (p *ptyStream) closeSlave() {
+	p.slave.Close()
+}
+
+// wait returns once all of the output has been copied.
+func (p *ptyStream) wait() {
+	<-p.done
+}
+
+func (p *ptyStream) close() error {
+	return p.master.Close()
+}
+
+// ptyReader reads from a pty master returning EOF rather than the EIO
+// that linux returns once the slave has been closed.
+type ptyReader struct {
+	*os.File
+}
+
+func (r ptyReader) Read(b []byte) (int, error) {
+	n, err := r.File.Read(b)
+	if err != nil && errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// terminalFor returns the terminal that filtered output will be written to
+// (or nil if it isn't filtered or isn't going to a terminal).
+func terminalFor(filters []filterPipe) *os.File {
+	if len(filters) == 0 {
+		return nil
+	}
+	f, ok := filters[0].output.(*os.File)
+	if !ok || !isTerminal(f) {
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,30 @@
+// +build !darwin,!linux
+
+package proc
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoPTY = errors.New("pseudo-terminals are not supported on this system")
+
+// openPTY returns an error on systems without pty support.
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errNoPTY
+}
+
+// isTerminal is false so that a pty will not be used.
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+// copyWinsize does nothing on systems without pty support.
+func copyWinsize(terminal, pty *os.File) error {
+	return errNoPTY
+}
+
+// rawOutput does nothing on systems without pty support.
+func rawOutput(f *os.File) error {
+	return errNoPTY
+}
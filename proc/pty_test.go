@@ -0,0 +1,120 @@
+// +build darwin linux
+
+package proc
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// testTerminal returns a pty slave to be used as the terminal
+// that the delegated output ends up on (and its master to read from).
+func testTerminal(t *testing.T) (*os.File, *os.File) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("unable to open pty: %s", err)
+	}
+	if err := rawOutput(slave); err != nil {
+		t.Fatal(err)
+	}
+	if err := control(slave, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: 40, Col: 100})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return master, slave
+}
+
+func TestDelegatorPTY(t *testing.T) {
+	script := `if [ -t 1 ]; then echo tty; else echo pipe; fi; stty size <&1; if [ -t 2 ]; then echo tty >&2; else echo pipe >&2; fi`
+
+	t.Run("filtered output to a terminal", func(t *testing.T) {
+		master, slave := testTerminal(t)
+		defer master.Close()
+		defer slave.Close()
+
+		var stderr bytes.Buffer
+		d := &Delegator{
+			Stdout: slave,
+			Stderr: &stderr,
+			UsePTY: true,
+		}
+		d.FilterStdout(newTestFilter())
+		d.FilterStderr(newTestFilter())
+
+		err := d.Delegate(exec.Command("/bin/sh", "-c", script))
+		assert.Nil(t, err)
+
+		lines := make([]string, 0, 3)
+		scanner := bufio.NewScanner(master)
+		for len(lines) < 3 && scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		assert.Equal(t, []string{"1 tty", "2 40 100", "done"}, lines, "stdout gets a pty with the terminal size")
+		assert.Equal(t, "1 pipe\ndone\n", stderr.String(), "stderr isn't a terminal")
+		assert.Nil(t, d.ptys, "ptys closed")
+	})
+
+	t.Run("resize", func(t *testing.T) {
+		master, slave := testTerminal(t)
+		defer master.Close()
+		defer slave.Close()
+
+		p, err := newPTYStream(slave, &bytes.Buffer{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.close()
+
+		control(slave, func(fd int) error {
+			return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: 20, Col: 30})
+		})
+		assert.Nil(t, p.resize())
+
+		var ws *unix.Winsize
+		control(p.slave, func(fd int) (err error) {
+			ws, err = unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+			return
+		})
+		assert.Equal(t, uint16(20), ws.Row)
+		assert.Equal(t, uint16(30), ws.Col)
+
+		p.closeSlave()
+		p.wait()
+	})
+
+	t.Run("not used without a terminal", func(t *testing.T) {
+		var stdout bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+			UsePTY: true,
+		}
+		d.FilterStdout(newTestFilter())
+
+		d.Delegate(exec.Command("/bin/sh", "-c", "if [ -t 1 ]; then echo tty; else echo pipe; fi"))
+
+		assert.Equal(t, "1 pipe\ndone\n", stdout.String())
+	})
+
+	t.Run("not used unless filtered", func(t *testing.T) {
+		master, slave := testTerminal(t)
+		defer master.Close()
+		defer slave.Close()
+
+		d := &Delegator{
+			Stdout: slave,
+			UsePTY: true,
+		}
+		cmd := exec.Command("true")
+		d.Delegate(cmd)
+
+		assert.Equal(t, slave, cmd.Stdout, "uses the terminal directly")
+	})
+}
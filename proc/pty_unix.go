@@ -0,0 +1,60 @@
+// +build darwin linux
+
+package proc
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// control calls the function with the file descriptor
+// (without changing the file to blocking mode like Fd() does).
+func control(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	return control(f, func(fd int) error {
+		_, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+		return err
+	}) == nil
+}
+
+// copyWinsize sets the window size of the pty to that of the terminal.
+func copyWinsize(terminal, pty *os.File) error {
+	var ws *unix.Winsize
+	if err := control(terminal, func(fd int) (err error) {
+		ws, err = unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+		return
+	}); err != nil {
+		return err
+	}
+	return control(pty, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, ws)
+	})
+}
+
+// rawOutput stops the terminal from processing output
+// (like translating "\n" to "\r\n") since the terminal we copy to will do it.
+func rawOutput(f *os.File) error {
+	return control(f, func(fd int) error {
+		t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+		if err != nil {
+			return err
+		}
+		t.Oflag &^= unix.OPOST
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, t)
+	})
+}
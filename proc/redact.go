@@ -0,0 +1,80 @@
+package proc
+
+import (
+	"bytes"
+)
+
+// RedactMask is written in place of any redacted values.
+const RedactMask = "********"
+
+// Redact returns content with each of the values replaced by RedactMask.
+// Values should be sorted longest first so that a value containing another
+// value is masked completely.
+func Redact(content []byte, values []string) []byte {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		content = bytes.Replace(content, []byte(v), []byte(RedactMask), -1)
+	}
+	return content
+}
+
+// NewRedactFilter returns a StreamFilter that masks any of the provided
+// values in the stream.
+func NewRedactFilter(values []string) StreamFilter {
+	// The default split keeps line endings and ansi sequences intact
+	// so that the output is unchanged apart from the masking.
+	f := NewLineFilter(func(line []byte) ([]byte, error) {
+		return Redact(line, values), nil
+	})
+	f.cut = redactCut(values)
+	return f
+}
+
+// redactCut returns where to break a line that is too long to mask at once:
+// far enough from the end that any value starting before the break is
+// complete, and moved back to the start of any value that crosses it
+// (so the value is masked with the next piece).
+func redactCut(values []string) func(data []byte) int {
+	longest := 0
+	for _, v := range values {
+		if len(v) > longest {
+			longest = len(v)
+		}
+	}
+
+	return func(data []byte) int {
+		if longest < 2 {
+			return len(data)
+		}
+		cut := len(data) - (longest - 1)
+		for moved := true; moved && cut > 0; {
+			moved = false
+			for _, v := range values {
+				if v == "" {
+					continue
+				}
+				// Look for the value starting just before the cut.
+				start := cut - len(v) + 1
+				if start < 0 {
+					start = 0
+				}
+				end := cut + len(v) - 1
+				if end > len(data) {
+					end = len(data)
+				}
+				if i := bytes.Index(data[start:end], []byte(v)); i >= 0 && start+i < cut {
+					cut = start + i
+					moved = true
+				}
+			}
+		}
+		// It can only be all values (which are masked anyway)
+		// and it has to make progress.
+		if cut <= 0 {
+			return len(data)
+		}
+		return cut
+	}
+}
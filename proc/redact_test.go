@@ -0,0 +1,72 @@
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	t.Run("values", func(t *testing.T) {
+		assert.Equal(t,
+			"a ******** b ******** c",
+			string(Redact([]byte("a s3cret b hunter2 c"), []string{"hunter2", "s3cret"})))
+
+		assert.Equal(t,
+			"token=********\n",
+			string(Redact([]byte("token=abcdef\n"), []string{"abcdef", "abc"})),
+			"longest first masks completely")
+
+		assert.Equal(t,
+			"nothing to see",
+			string(Redact([]byte("nothing to see"), []string{""})),
+			"empty values ignored")
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		d := &Delegator{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		}
+		d.FilterStdout(NewRedactFilter([]string{"hunter2"}))
+		d.FilterStderr(NewRedactFilter([]string{"hunter2"}))
+
+		d.Delegate(
+			exec.Command("/bin/sh", "-c", `echo "pw: hunter2"; printf 'err hunter2\r\n\033[1Ahunter2' >&2`),
+		)
+
+		assert.Equal(t, "pw: ********\n", stdout.String())
+		assert.Equal(t, "err ********\r\n\033[1A********", stderr.String(), "preserves line endings and ansi")
+	})
+
+	t.Run("long lines", func(t *testing.T) {
+		// Cross the point where a line that is too long is broken up.
+		for _, offset := range []int{12, 6, 1} {
+			var stdout bytes.Buffer
+			d := &Delegator{
+				Stdout: &stdout,
+			}
+			d.FilterStdout(NewRedactFilter([]string{"hunter2", "s3cret-value"}))
+
+			prefix := maxTokenSize - offset
+			err := d.Delegate(exec.Command("/bin/sh", "-c", fmt.Sprintf("head -c %d /dev/zero | tr '\\0' x; printf 's3cret-value hunter2 %%s\\n' $0", prefix), strings.Repeat("y", 100)))
+
+			assert.Nil(t, err)
+			assert.Equal(t, strings.Repeat("x", prefix)+"******** ******** "+strings.Repeat("y", 100)+"\n", stdout.String(), "masked across the break (%d)", offset)
+		}
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		cut := redactCut([]string{"abcdef", "xya"})
+
+		assert.Equal(t, 8, cut([]byte("0123456789abc")), "leaves enough for the longest value")
+		assert.Equal(t, 6, cut([]byte("012345abcdef00")), "before a value that crosses")
+		assert.Equal(t, 4, cut([]byte("0123xyabcdef00")), "and any that crosses that")
+		assert.Equal(t, 6, cut([]byte("abcdef")), "progresses")
+	})
+}
@@ -0,0 +1,100 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Result describes how a delegated command finished.
+type Result struct {
+	Args     []string
+	ExitCode int
+	Duration time.Duration
+	Err      error
+	// Canceled is true if the command was signaled because another one failed.
+	Canceled bool
+}
+
+// Failed returns true if the command did not succeed.
+func (r *Result) Failed() bool {
+	return r.Err != nil
+}
+
+// String returns the command line (quoting args that contain spaces).
+func (r *Result) String() string {
+	args := make([]string, len(r.Args))
+	for i, arg := range r.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		args[i] = arg
+	}
+	return strings.Join(args, " ")
+}
+
+func newResult(cmd *exec.Cmd, err error, duration time.Duration) *Result {
+	r := &Result{
+		Args:     cmd.Args,
+		Duration: duration,
+		Err:      err,
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		r.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		// The command didn't start (or couldn't be waited on).
+		r.ExitCode = -1
+	}
+	return r
+}
+
+// DelegateError is returned when delegating to multiple commands
+// and any of them fail.
+type DelegateError struct {
+	// Results holds the result of every command in the order given.
+	Results []*Result
+	// first is the command that failed first.
+	first *Result
+}
+
+// Failed returns the results of the commands that failed.
+func (e *DelegateError) Failed() []*Result {
+	failed := make([]*Result, 0, len(e.Results))
+	for _, r := range e.Results {
+		if r.Failed() {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Error returns a message describing each failed command.
+func (e *DelegateError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, len(failed))
+	for i, r := range failed {
+		msgs[i] = fmt.Sprintf("%s: %s", r, r.Err)
+	}
+	return fmt.Sprintf("%d of %d commands failed: %s",
+		len(failed), len(e.Results), strings.Join(msgs, "; "))
+}
+
+// ExitCode returns the exit code of the command that failed first
+// (or 1 if it did not exit on its own).
+func (e *DelegateError) ExitCode() int {
+	if e.first != nil && e.first.ExitCode > 0 {
+		return e.first.ExitCode
+	}
+	return 1
+}
+
+// Unwrap returns the error of the command that failed first.
+func (e *DelegateError) Unwrap() error {
+	if e.first == nil {
+		return nil
+	}
+	return e.first.Err
+}
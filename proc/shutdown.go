@@ -0,0 +1,123 @@
+package proc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Stages of shutting down the delegated commands.
+const (
+	shutdownNone = iota
+	// The commands have been signaled (or interrupted from the terminal).
+	shutdownSignaled
+	// The commands have been sent SIGTERM.
+	shutdownTerminated
+	// The commands have been killed.
+	shutdownKilled
+)
+
+// shutdown escalates from the first signal to SIGTERM to SIGKILL
+// for commands that do not exit within the grace period.
+type shutdown struct {
+	stage    int
+	grace    time.Duration
+	timer    *time.Timer
+	messages io.Writer
+	send     func(*exec.Cmd, os.Signal)
+}
+
+// timeout returns a channel that fires when the current stage has run out
+// of time (or nil if there is no grace period or nothing left to do).
+func (s *shutdown) timeout() <-chan time.Time {
+	if s.timer == nil {
+		return nil
+	}
+	return s.timer.C
+}
+
+func (s *shutdown) stop() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// begin marks the commands as signaled and starts the grace period.
+func (s *shutdown) begin(waiting []*exec.Cmd) {
+	if s.stage != shutdownNone {
+		return
+	}
+	s.stage = shutdownSignaled
+	if len(waiting) == 0 {
+		return
+	}
+	limit := ""
+	if s.grace > 0 {
+		limit = fmt.Sprintf(" up to %s", s.grace)
+		s.restart()
+	}
+	s.message("Waiting%s for %s to exit (press Ctrl-C again to stop now)...", limit, describeCmds(waiting))
+}
+
+// escalate sends the next (stronger) signal to the commands
+// that are still running.
+func (s *shutdown) escalate(waiting []*exec.Cmd) {
+	s.stop()
+	if s.stage >= shutdownKilled || len(waiting) == 0 {
+		return
+	}
+
+	if s.stage < shutdownTerminated {
+		s.stage = shutdownTerminated
+		s.message("Sending SIGTERM to %s...", describeCmds(waiting))
+		for _, cmd := range waiting {
+			s.send(cmd, syscall.SIGTERM)
+		}
+		if s.grace > 0 {
+			s.restart()
+		}
+		return
+	}
+
+	s.stage = shutdownKilled
+	s.message("Killing %s...", describeCmds(waiting))
+	for _, cmd := range waiting {
+		s.send(cmd, os.Kill)
+	}
+}
+
+func (s *shutdown) restart() {
+	s.stop()
+	s.timer = time.NewTimer(s.grace)
+}
+
+func (s *shutdown) message(format string, args ...interface{}) {
+	if s.messages == nil {
+		return
+	}
+	fmt.Fprintf(s.messages, "muss: "+format+"\n", args...)
+}
+
+// describeCmds names the commands (and their pids) for messages.
+func describeCmds(cmds []*exec.Cmd) string {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		args := append([]string{filepath.Base(cmd.Args[0])}, cmd.Args[1:]...)
+		name := strings.Join(args, " ")
+		if len(name) > 40 {
+			name = name[:37] + "..."
+		}
+		pid := 0
+		if cmd.Process != nil {
+			pid = cmd.Process.Pid
+		}
+		names[i] = fmt.Sprintf("%q (pid %d)", name, pid)
+	}
+	return strings.Join(names, ", ")
+}
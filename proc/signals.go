@@ -6,8 +6,7 @@ import (
 	"syscall"
 )
 
-func setupSignals(c chan os.Signal) {
-	signals := []os.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM}
+func setupSignals(c chan os.Signal, signals []os.Signal) {
 	for _, sig := range signals {
 		if !signal.Ignored(sig) {
 			signal.Notify(c, sig)
@@ -18,3 +17,18 @@ func setupSignals(c chan os.Signal) {
 func restoreSignals(c chan os.Signal) {
 	signal.Stop(c)
 }
+
+// terminating returns true for signals that ask the commands to exit.
+func terminating(sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM:
+		return true
+	}
+	return false
+}
+
+// fromTerminal returns true for signals that the terminal sends
+// to every process in the foreground process group.
+func fromTerminal(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGQUIT
+}
@@ -0,0 +1,39 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// DefaultSignals are the signals forwarded to delegated commands
+// when the Delegator does not specify any.
+var DefaultSignals = []os.Signal{
+	syscall.SIGHUP,
+	syscall.SIGINT,
+	syscall.SIGTERM,
+}
+
+// resumeSignal is nil on systems without job control.
+var resumeSignal os.Signal
+
+// resizeSignal is nil on systems without SIGWINCH.
+var resizeSignal os.Signal
+
+// setProcessGroup does nothing on systems without process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup signals just the process on systems without process groups.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+// isSuspend is false on systems without job control.
+func isSuspend(sig os.Signal) bool {
+	return false
+}
+
+// suspend does nothing on systems without job control.
+func suspend() {}
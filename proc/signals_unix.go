@@ -0,0 +1,59 @@
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// DefaultSignals are the signals forwarded to delegated commands
+// when the Delegator does not specify any.
+var DefaultSignals = []os.Signal{
+	syscall.SIGHUP,
+	syscall.SIGINT,
+	syscall.SIGQUIT,
+	syscall.SIGTERM,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
+	syscall.SIGTSTP,
+	syscall.SIGCONT,
+}
+
+// resumeSignal continues stopped processes.
+var resumeSignal os.Signal = syscall.SIGCONT
+
+// resizeSignal is sent when the terminal window changes size.
+var resizeSignal os.Signal = syscall.SIGWINCH
+
+// setProcessGroup starts the command in a new process group
+// (with the same id as its pid).
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends the signal to every process in the process group
+// led by the process.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+// isSuspend returns true for the signal sent by Ctrl-Z.
+func isSuspend(sig os.Signal) bool {
+	return sig == syscall.SIGTSTP
+}
+
+// suspend stops the current process (like the default action for SIGTSTP)
+// and returns once it has been continued.
+func suspend() {
+	syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+}
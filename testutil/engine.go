@@ -0,0 +1,155 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeContainer is a container served by FakeEngine.
+type FakeContainer struct {
+	ID      string
+	Project string
+	Service string
+	Number  int
+	// State defaults to "running".
+	State string
+	// ExitCode is reported for containers that aren't running.
+	ExitCode int
+	OneOff   bool
+	// Health is the status of the healthcheck ("" for none).
+	Health string
+	// Starting is the number of times the container will be inspected
+	// with a health status of "starting" before it has Health.
+	Starting int
+}
+
+func (c FakeContainer) labels() map[string]string {
+	oneOff := "False"
+	if c.OneOff {
+		oneOff = "True"
+	}
+	return map[string]string{
+		"com.docker.compose.project":          c.Project,
+		"com.docker.compose.service":          c.Service,
+		"com.docker.compose.container-number": strconv.Itoa(c.Number),
+		"com.docker.compose.oneoff":           oneOff,
+	}
+}
+
+func (c FakeContainer) state() string {
+	if c.State == "" {
+		return "running"
+	}
+	return c.State
+}
+
+// FakeEngine serves the container list (and inspection) of the Engine API
+// on a unix socket in a temp dir.  It returns the path to the socket and a func to stop it.
+// Requests for anything else get a 404 (with the API's error format).
+func FakeEngine(t *testing.T, containers []FakeContainer) (string, func()) {
+	t.Helper()
+	dir := Tempdir(t)
+	socket := path.Join(dir, "engine.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("error listening on %s: %s", socket, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "page not found"})
+	})
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		var filters map[string][]string
+		if f := r.URL.Query().Get("filters"); f != "" {
+			if err := json.Unmarshal([]byte(f), &filters); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+				return
+			}
+		}
+		all := r.URL.Query().Get("all") == "1"
+
+		result := make([]map[string]interface{}, 0, len(containers))
+		for _, c := range containers {
+			if !fakeContainerMatches(c, filters, all) {
+				continue
+			}
+			result = append(result, map[string]interface{}{
+				"Id":     c.ID,
+				"Names":  []string{"/" + c.Project + "_" + c.Service + "_" + strconv.Itoa(c.Number)},
+				"State":  c.state(),
+				"Labels": c.labels(),
+			})
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+
+	var mutex sync.Mutex
+	inspected := make(map[string]int)
+	mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+		for _, c := range containers {
+			if c.ID != id {
+				continue
+			}
+			mutex.Lock()
+			inspected[id]++
+			count := inspected[id]
+			mutex.Unlock()
+
+			state := map[string]interface{}{
+				"Status":   c.state(),
+				"Running":  c.state() == "running",
+				"ExitCode": c.ExitCode,
+			}
+			health := c.Health
+			if count <= c.Starting {
+				health = "starting"
+			}
+			if health != "" {
+				state["Health"] = map[string]string{"Status": health}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Id": c.ID, "State": state})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + id})
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	return socket, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func fakeContainerMatches(c FakeContainer, filters map[string][]string, all bool) bool {
+	if !all && c.state() != "running" {
+		return false
+	}
+	for _, status := range filters["status"] {
+		if c.state() != status {
+			return false
+		}
+	}
+	labels := c.labels()
+	for _, label := range filters["label"] {
+		parts := strings.SplitN(label, "=", 2)
+		value, ok := labels[parts[0]]
+		if !ok || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
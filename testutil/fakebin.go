@@ -0,0 +1,271 @@
+package testutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Env vars that tell the test binary to act as a fake command.
+const (
+	fakeBinDirVar  = "MUSS_FAKEBIN_DIR"
+	fakeBinNameVar = "MUSS_FAKEBIN_NAME"
+)
+
+// FakeOutput is written by a fake command after waiting for Delay.
+type FakeOutput struct {
+	Delay  time.Duration
+	Stdout string
+	Stderr string
+}
+
+// FakeResponse scripts what a fake command does when it is called.
+type FakeResponse struct {
+	Stdout string
+	Stderr string
+	// Output is written (in order) after Stdout and Stderr.
+	Output   []FakeOutput
+	ExitCode int
+	// ReadStdin records stdin (reading until it is closed) before responding.
+	ReadStdin bool
+	// WaitForSignal keeps the command running (after writing its output)
+	// until it receives SIGINT, SIGTERM or SIGHUP (IgnoreSignals of which
+	// are recorded but ignored first).  It then exits with ExitCode.
+	WaitForSignal bool
+	IgnoreSignals int
+}
+
+// FakeCall is a recorded invocation of a fake command.
+type FakeCall struct {
+	Pid   int               `json:"pid"`
+	Name  string            `json:"name"`
+	Args  []string          `json:"args"`
+	Env   map[string]string `json:"env"`
+	Stdin string            `json:"stdin"`
+	// Signals received (for commands waiting for them).
+	Signals []string `json:"signals"`
+}
+
+type fakeRule struct {
+	Name     string       `json:"name"`
+	Args     []string     `json:"args"`
+	Response FakeResponse `json:"response"`
+}
+
+// fakeRecord is a line in the calls file: either a call or a signal
+// received by the call with that pid.
+type fakeRecord struct {
+	Call   *FakeCall `json:"call,omitempty"`
+	Pid    int       `json:"pid,omitempty"`
+	Signal string    `json:"signal,omitempty"`
+}
+
+// FakeBin installs fake commands in a temp dir at the front of PATH.
+// The commands run the test binary so the test package must call
+// RunFakeBin from TestMain.
+type FakeBin struct {
+	Dir   string
+	t     *testing.T
+	path  string
+	rules []fakeRule
+}
+
+// NewFakeBin creates fake commands with the given names.
+// Until they are scripted with On they exit successfully without output.
+// Call Close to restore PATH.
+func NewFakeBin(t *testing.T, names ...string) *FakeBin {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("error finding test binary: %s", err)
+	}
+
+	f := &FakeBin{Dir: Tempdir(t), t: t, path: os.Getenv("PATH")}
+	for _, name := range names {
+		script := fmt.Sprintf("#!/bin/sh\n%s=%s %s=%s exec %s \"$@\"\n",
+			fakeBinDirVar, shellQuote(f.Dir), fakeBinNameVar, shellQuote(name), shellQuote(exe))
+		file := filepath.Join(f.Dir, name)
+		if err := ioutil.WriteFile(file, []byte(script), 0700); err != nil {
+			t.Fatalf("error writing fake %s: %s", name, err)
+		}
+	}
+	f.writeRules()
+
+	os.Setenv("PATH", f.Dir+string(os.PathListSeparator)+f.path)
+	return f
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// On scripts the response of the named command when its args start with
+// the given args.  Rules are checked in the order they were added.
+func (f *FakeBin) On(name string, args []string, response FakeResponse) {
+	f.rules = append(f.rules, fakeRule{Name: name, Args: args, Response: response})
+	f.writeRules()
+}
+
+func (f *FakeBin) writeRules() {
+	content, err := json.Marshal(f.rules)
+	if err != nil {
+		f.t.Fatalf("error encoding fake responses: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(f.Dir, "rules.json"), content, 0600); err != nil {
+		f.t.Fatalf("error writing fake responses: %s", err)
+	}
+}
+
+// Calls returns the recorded calls of the named command
+// (or of all of them if name is empty) in the order they started.
+func (f *FakeBin) Calls(name string) []*FakeCall {
+	f.t.Helper()
+	file, err := os.Open(filepath.Join(f.Dir, "calls.jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		f.t.Fatalf("error reading fake calls: %s", err)
+	}
+	defer file.Close()
+
+	var calls []*FakeCall
+	byPid := make(map[int]*FakeCall)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var record fakeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			f.t.Fatalf("error decoding fake call: %s", err)
+		}
+		if record.Call != nil {
+			byPid[record.Call.Pid] = record.Call
+			if name == "" || record.Call.Name == name {
+				calls = append(calls, record.Call)
+			}
+		} else if call, ok := byPid[record.Pid]; ok {
+			call.Signals = append(call.Signals, record.Signal)
+		}
+	}
+	return calls
+}
+
+// Args returns the args of each recorded call of the named command.
+func (f *FakeBin) Args(name string) [][]string {
+	f.t.Helper()
+	calls := f.Calls(name)
+	args := make([][]string, len(calls))
+	for i, call := range calls {
+		args[i] = call.Args
+	}
+	return args
+}
+
+// Close restores PATH and removes the fake commands.
+func (f *FakeBin) Close() {
+	os.Setenv("PATH", f.path)
+	os.RemoveAll(f.Dir)
+}
+
+// RunFakeBin acts as a fake command (and exits) if the test binary was
+// run by one; otherwise it returns.  Call it first thing in TestMain.
+func RunFakeBin() {
+	dir := os.Getenv(fakeBinDirVar)
+	if dir == "" {
+		return
+	}
+	os.Exit(runFakeBin(dir, os.Getenv(fakeBinNameVar), os.Args[1:]))
+}
+
+func runFakeBin(dir, name string, args []string) int {
+	response := findFakeResponse(dir, name, args)
+
+	// Start listening before recording the call so that tests that wait
+	// for the call can signal it.
+	signals := make(chan os.Signal, 1)
+	if response.WaitForSignal {
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	}
+
+	call := &FakeCall{Pid: os.Getpid(), Name: name, Args: args, Env: make(map[string]string)}
+	if call.Args == nil {
+		call.Args = []string{}
+	}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if parts[0] != fakeBinDirVar && parts[0] != fakeBinNameVar {
+			call.Env[parts[0]] = parts[1]
+		}
+	}
+	if response.ReadStdin {
+		stdin, _ := ioutil.ReadAll(os.Stdin)
+		call.Stdin = string(stdin)
+	}
+	recordFake(dir, fakeRecord{Call: call})
+
+	os.Stdout.WriteString(response.Stdout)
+	os.Stderr.WriteString(response.Stderr)
+	for _, out := range response.Output {
+		time.Sleep(out.Delay)
+		os.Stdout.WriteString(out.Stdout)
+		os.Stderr.WriteString(out.Stderr)
+	}
+
+	if response.WaitForSignal {
+		for i := 0; ; i++ {
+			sig := <-signals
+			recordFake(dir, fakeRecord{Pid: call.Pid, Signal: sig.String()})
+			if i >= response.IgnoreSignals {
+				break
+			}
+		}
+	}
+	return response.ExitCode
+}
+
+func findFakeResponse(dir, name string, args []string) FakeResponse {
+	var rules []fakeRule
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "rules.json")); err == nil {
+		json.Unmarshal(content, &rules)
+	}
+	for _, rule := range rules {
+		if rule.Name == name && hasArgsPrefix(args, rule.Args) {
+			return rule.Response
+		}
+	}
+	return FakeResponse{}
+}
+
+func hasArgsPrefix(args, prefix []string) bool {
+	if len(prefix) > len(args) {
+		return false
+	}
+	for i, arg := range prefix {
+		if args[i] != arg {
+			return false
+		}
+	}
+	return true
+}
+
+// recordFake appends a line to the calls file.
+// Each record is a single (appended) write so concurrent calls don't mix.
+func recordFake(dir string, record fakeRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "calls.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}